@@ -0,0 +1,101 @@
+package cmdr
+import (
+  "errors"
+  "fmt"
+  "os"
+  "os/exec"
+  "strings"
+)
+
+
+// Expands a user-defined alias at the start of args. Aliases are defined in config files
+// (ConfigName) with keys like "alias.st", whose value is either a command with arguments, such as
+// "status -short", or a shell command prefixed by "!", which is run with the alias's arguments.
+// Aliases can expand to other aliases but never shadow commands. Returns a command running the
+// shell command for "!" aliases.
+func (p *Program) expandAlias(args []string) ([]string, *Command, error) {
+  c, _ := p.loadConfig()
+  if c == nil {
+    return args, nil, nil
+  }
+  var seen []string
+  for !p.hasCommand(args[0]) {
+    name := args[0]
+    entry, ok := c.values["alias." + name]
+    if !ok {
+      break
+    }
+    for _, n := range seen {
+      if n == name {
+        return args, nil, fmt.Errorf("alias loop: %s", strings.Join(append(seen, name), " -> "))
+      }
+    }
+    seen = append(seen, name)
+    if strings.HasPrefix(entry.value, "!") {
+      return args, shellAliasCommand(p, name, entry.value[1:]), nil
+    }
+    words, err := splitWords(entry.value)
+    if err != nil {
+      return args, nil, fmt.Errorf("alias %q: %v", name, err)
+    }
+    if len(words) == 0 {
+      return args, nil, fmt.Errorf("alias %q is empty", name)
+    }
+    args = append(words, args[1:]...)
+  }
+  return args, nil, nil
+}
+
+
+// True if `name` is the name or an alias of one of p's commands
+func (p *Program) hasCommand(name string) bool {
+  if name == "help" {
+    return true
+  }
+  for cmdName, cmd := range p.Commands {
+    if cmdName == name {
+      return true
+    }
+    for _, alias := range cmd.Aliases {
+      if alias == name {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+
+// Returns a command which runs `script` with sh, passing the command's arguments on to it
+func shellAliasCommand(p *Program, name, script string) *Command {
+  cmd := NewCommand(name, "", nil)
+  cmd.Program = p
+  cmd.rawArgs = true
+  cmd.main = func(cmd *Command) {
+    args := append([]string{"-c", script + ` "$@"`, name}, cmd.Options.Args()...)
+    runExternal(cmd, exec.Command("sh", args...))
+  }
+  return cmd
+}
+
+
+// Runs c with the standard streams of the process, exiting with c's exit status if it fails.
+// c.Stdin is left alone if set.
+func runExternal(cmd *Command, c *exec.Cmd) {
+  if c.Stdin == nil {
+    c.Stdin = os.Stdin
+  }
+  c.Stdout, c.Stderr = os.Stdout, os.Stderr
+  err := c.Run()
+  var exitErr *exec.ExitError
+  if errors.As(err, &exitErr) && cmd.Program != nil && cmd.Program.inShell {
+    return // the command has reported the failure itself
+  } else if errors.As(err, &exitErr) {
+    if code := exitErr.ExitCode(); code > 0 {
+      os.Exit(code)
+    }
+    os.Exit(1)
+  } else if err != nil {
+    cmd.Fail(err)
+  }
+}
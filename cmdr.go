@@ -5,6 +5,9 @@ import (
   "time"
   "text/tabwriter"
   "fmt"
+  "io"
+  "strings"
+  "path/filepath"
 )
 
 
@@ -17,6 +20,26 @@ var DefaultProgram = &Program{
 }
 
 
+// Makes DefaultProgram use its own FlagSet for options instead of flag.CommandLine, and names it
+// after the base name of the executable rather than its full path. Flags defined with the
+// standard "flag" package, for instance by imported packages, are then no longer part of
+// DefaultProgram's options, unless added with ImportStdFlags.
+// Should be called before any program options are defined.
+func UsePrivateFlagSet() {
+  name := filepath.Base(os.Args[0])
+  DefaultProgram.Name = name
+  DefaultProgram.Options = flag.NewFlagSet(name, flag.ExitOnError)
+  DefaultProgram.options = nil
+}
+
+
+// Adds the flags defined with the standard "flag" package (flag.CommandLine) to the options of
+// DefaultProgram. Only useful after UsePrivateFlagSet.
+func ImportStdFlags() {
+  DefaultProgram.ImportFlags(flag.CommandLine)
+}
+
+
 // Create and add a new command to the DefaultProgram
 func Cmd(name, description string, fn interface{}) *Command {
   cmd := NewCommand(name, description, fn)
@@ -47,64 +70,156 @@ func flagIsString(f *flag.Flag) bool {
   return false
 }
 
-func optionsUsage(flagSet *flag.FlagSet) {
+// Prints usage for the options of flagSet. Only options inherited from the program are printed
+// when `inherited` is true, or only options which are not inherited when false, along with
+// -show-config when accepted.
+func optionsUsage(
+  p *Program, flagSet *flag.FlagSet, options map[string]*optionInfo, inherited bool,
+) {
   w := newTabWriter()
 
   flagSet.VisitAll(func(f *flag.Flag) {
-    v := f.Value
-    if _, ok := v.(*boolValue); ok {
-      if f.DefValue == "false" {
-        fmt.Fprintf(w, "  -%s\t%s\n", f.Name, f.Usage)
-      } else {
-        fmt.Fprintf(w, "  -%s=true\t%s\n", f.Name, f.Usage)
+    info := options[f.Name]
+    if info.isHidden() || (info != nil && info.inherited) != inherited {
+      return
+    }
+    if p != nil && p.GNUOptions {
+      gnuOptionUsage(w, f, info)
+    } else if _, ok := implicitValue(f.Value); ok {
+      name := f.Name
+      if isNegatable(f, info) {
+        name = "[no-]" + name
       }
+      fmt.Fprintf(w, "  -%s[=%s]\t%s (default: %s)",
+                  name, optionArgName(f, info), f.Usage, f.DefValue)
+    } else if isNegatable(f, info) {
+      fmt.Fprintf(w, "  -[no-]%s\t%s%s", f.Name, f.Usage, boolDefaultUsage(f))
+    } else if isBoolFlag(f.Value) {
+      fmt.Fprintf(w, "  -%s\t%s%s", f.Name, f.Usage, boolDefaultUsage(f))
     } else {
       if flagIsString(f) {
-        fmt.Fprintf(w, "  -%s %q\t%s\n", f.Name, f.DefValue, f.Usage)
+        fmt.Fprintf(w, "  -%s %q\t%s", f.Name, f.DefValue, f.Usage)
       } else {
-        fmt.Fprintf(w, "  -%s %s\t%s\n", f.Name, f.DefValue, f.Usage)
+        fmt.Fprintf(w, "  -%s %s\t%s", f.Name, f.DefValue, f.Usage)
       }
     }
+    if env := p.optionEnv(f.Name, info); env != "" {
+      fmt.Fprintf(w, " [$%s]", env)
+    }
+    io.WriteString(w, "\n")
   })
 
+  if !inherited && p.showConfigOption(flagSet) {
+    if p.GNUOptions {
+      io.WriteString(w, "      --show-config")
+    } else {
+      io.WriteString(w, "  -show-config")
+    }
+    io.WriteString(w, "\tShow option values and where they came from\n")
+  }
+
   w.Flush()
 }
 
+// Returns a note on the default value of a boolean option, if it's not false
+func boolDefaultUsage(f *flag.Flag) string {
+  if f.DefValue != "false" {
+    return " (default: " + f.DefValue + ")"
+  }
+  return ""
+}
+
+// Returns the name of an option's value for usage, e.g. "FILE" or "OUTPUT_DIR"
+func optionArgName(f *flag.Flag, info *optionInfo) string {
+  if info != nil && info.argName != "" {
+    return info.argName
+  }
+  return strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+}
+
+// Writes usage for an option in GNU style, e.g. "-o, --output FILE  Output file"
+func gnuOptionUsage(w io.Writer, f *flag.Flag, info *optionInfo) {
+  name := f.Name
+  if isNegatable(f, info) {
+    name = "[no-]" + name
+  }
+  if info != nil && info.short != "" {
+    fmt.Fprintf(w, "  -%s, --%s", info.short, name)
+  } else {
+    fmt.Fprintf(w, "      --%s", name)
+  }
+  _, isOptional := implicitValue(f.Value)
+  isBool := isBoolFlag(f.Value) && !isOptional
+  if isOptional {
+    fmt.Fprintf(w, "[=%s]", optionArgName(f, info))
+  } else if !isBool {
+    fmt.Fprintf(w, " %s", optionArgName(f, info))
+  }
+  fmt.Fprintf(w, "\t%s", f.Usage)
+  if isBool {
+    io.WriteString(w, boolDefaultUsage(f))
+  } else if flagIsString(f) {
+    if f.DefValue != "" {
+      fmt.Fprintf(w, " (default: %q)", f.DefValue)
+    }
+  } else if f.DefValue != "" && f.DefValue != "0" {
+    fmt.Fprintf(w, " (default: %s)", f.DefValue)
+  }
+}
+
 // ==============================================================================================
 
+// Records `name` as an option of DefaultProgram itself rather than a flag defined by some
+// other package, so that it's passed on to external commands (see optionEnvVars)
+func defineOption(name string) {
+  p := DefaultProgram
+  if p.options == nil {
+    p.options = make(map[string]*optionInfo)
+  }
+  if p.options[name] == nil {
+    p.options[name] = &optionInfo{}
+  }
+}
+
 // BoolVar defines a bool flag with specified name, default value, and usage string.
 // The argument p points to a bool variable in which to store the value of the flag.
 func BoolVar(p *bool, name string, value bool, usage string) {
+  defineOption(name)
   DefaultProgram.Options.BoolVar(p, name, value, usage)
 }
 
 // Bool defines a bool flag with specified name, default value, and usage string.
 // The return value is the address of a bool variable that stores the value of the flag.
 func Bool(name string, value bool, usage string) *bool {
+  defineOption(name)
   return DefaultProgram.Options.Bool(name, value, usage)
 }
 
 // IntVar defines an int flag with specified name, default value, and usage string.
 // The argument p points to an int variable in which to store the value of the flag.
 func IntVar(p *int, name string, value int, usage string) {
+  defineOption(name)
   DefaultProgram.Options.IntVar(p, name, value, usage)
 }
 
 // Int defines an int flag with specified name, default value, and usage string.
 // The return value is the address of an int variable that stores the value of the flag.
 func Int(name string, value int, usage string) *int {
+  defineOption(name)
   return DefaultProgram.Options.Int(name, value, usage)
 }
 
 // Int64Var defines an int64 flag with specified name, default value, and usage string.
 // The argument p points to an int64 variable in which to store the value of the flag.
 func Int64Var(p *int64, name string, value int64, usage string) {
+  defineOption(name)
   DefaultProgram.Options.Int64Var(p, name, value, usage)
 }
 
 // Int64 defines an int64 flag with specified name, default value, and usage string.
 // The return value is the address of an int64 variable that stores the value of the flag.
 func Int64(name string, value int64, usage string) *int64 {
+  defineOption(name)
   return DefaultProgram.Options.Int64(name, value, usage)
 }
 
@@ -112,48 +227,56 @@ func Int64(name string, value int64, usage string) *int64 {
 // UintVar defines a uint flag with specified name, default value, and usage string.
 // The argument p points to a uint  variable in which to store the value of the flag.
 func UintVar(p *uint, name string, value uint, usage string) {
+  defineOption(name)
   DefaultProgram.Options.UintVar(p, name, value, usage)
 }
 
 // Uint defines a uint flag with specified name, default value, and usage string.
 // The return value is the address of a uint  variable that stores the value of the flag.
 func Uint(name string, value uint, usage string) *uint {
+  defineOption(name)
   return DefaultProgram.Options.Uint(name, value, usage)
 }
 
 // Uint64Var defines a uint64 flag with specified name, default value, and usage string.
 // The argument p points to a uint64 variable in which to store the value of the flag.
 func Uint64Var(p *uint64, name string, value uint64, usage string) {
+  defineOption(name)
   DefaultProgram.Options.Uint64Var(p, name, value, usage)
 }
 
 // Uint64 defines a uint64 flag with specified name, default value, and usage string.
 // The return value is the address of a uint64 variable that stores the value of the flag.
 func Uint64(name string, value uint64, usage string) *uint64 {
+  defineOption(name)
   return DefaultProgram.Options.Uint64(name, value, usage)
 }
 
 // StringVar defines a string flag with specified name, default value, and usage string.
 // The argument p points to a string variable in which to store the value of the flag.
 func StringVar(p *string, name string, value string, usage string) {
+  defineOption(name)
   DefaultProgram.Options.StringVar(p, name, value, usage)
 }
 
 // String defines a string flag with specified name, default value, and usage string.
 // The return value is the address of a string variable that stores the value of the flag.
 func String(name string, value string, usage string) *string {
+  defineOption(name)
   return DefaultProgram.Options.String(name, value, usage)
 }
 
 // Float64Var defines a float64 flag with specified name, default value, and usage string.
 // The argument p points to a float64 variable in which to store the value of the flag.
 func Float64Var(p *float64, name string, value float64, usage string) {
+  defineOption(name)
   DefaultProgram.Options.Float64Var(p, name, value, usage)
 }
 
 // Float64 defines a float64 flag with specified name, default value, and usage string.
 // The return value is the address of a float64 variable that stores the value of the flag.
 func Float64(name string, value float64, usage string) *float64 {
+  defineOption(name)
   return DefaultProgram.Options.Float64(name, value, usage)
 }
 
@@ -161,6 +284,7 @@ func Float64(name string, value float64, usage string) *float64 {
 // The argument p points to a time.Duration variable in which to store the value of the flag.
 // The flag accepts a value acceptable to time.ParseDuration.
 func DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
+  defineOption(name)
   DefaultProgram.Options.DurationVar(p, name, value, usage)
 }
 
@@ -168,6 +292,7 @@ func DurationVar(p *time.Duration, name string, value time.Duration, usage strin
 // The return value is the address of a time.Duration variable that stores the value of the flag.
 // The flag accepts a value acceptable to time.ParseDuration.
 func Duration(name string, value time.Duration, usage string) *time.Duration {
+  defineOption(name)
   return DefaultProgram.Options.Duration(name, value, usage)
 }
 
@@ -178,5 +303,6 @@ func Duration(name string, value time.Duration, usage string) *time.Duration {
 // of strings by giving the slice the methods of Value; in particular, Set would
 // decompose the comma-separated string into the slice.
 func Var(value flag.Value, name string, usage string) {
+  defineOption(name)
   DefaultProgram.Options.Var(value, name, usage)
 }
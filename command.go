@@ -10,18 +10,34 @@ import (
   "unicode/utf8"
   "regexp"
   "os"
+  "io"
+  "runtime"
+  "time"
+  "errors"
 )
 
 
 type Command struct {
   Name        string
+  Aliases     []string            // Alternative names, e.g. "ls" for "list"
   Description string
+  Group       string              // ID of the program's CommandGroup this command is listed in
+  Hidden      bool                // When true, the command is not listed in usage
+  Deprecated  string              // When non-empty, warn with this message when run
   Options     *flag.FlagSet
   OptionCount int
   Args        []Argument
   VarArgs     *Argument
   Program     *Program
+  Commands    map[string]*Command // Subcommands
+  Parent      *Command            // Command this is a subcommand of, if any
   main        func(*Command)
+  mounted     *Program            // Program this command was mounted from (Program.Mount)
+  cmdOrder    []string            // names of subcommands in the order they were added
+  options     map[string]*optionInfo
+  sources     map[string]ValueSource // where option values came from, if not their defaults
+  rawArgs     bool                // args are not parsed, only available from Options.Args()
+  reset       func()              // restores the initial values of options and arguments
 }
 
 type Argument struct {
@@ -41,6 +57,13 @@ type SliceBinding interface {
   Setv([]string) error
 }
 
+// A binding for an option whose value is optional, e.g. "-color" or "-color=never".
+// When the option is given without a value, it's set to ImplicitValue().
+type OptionalValueBinding interface {
+  ValueBinding
+  ImplicitValue() string
+}
+
 
 func (cmd *Command) Logf(format string, a ...interface{}) {
   if cmd.IsQuiet() { return }
@@ -56,6 +79,11 @@ func (cmd *Command) Log(a ...interface{}) {
 
 
 func (cmd *Command) IsQuiet() bool {
+  for c := cmd; c != nil; c = c.Parent {
+    if c.mounted != nil && c.mounted.IsQuiet {
+      return true
+    }
+  }
   if cmd.Program != nil {
     return cmd.Program.IsQuiet
   }
@@ -64,25 +92,70 @@ func (cmd *Command) IsQuiet() bool {
 
 
 // Prints a message from `fmt.Sprint(msg...)` together with info on how to invoke help,
-// finally calling os.Exit(1)
+// finally calling os.Exit(1). In a shell (ShellCommand), the command is aborted instead.
 func (cmd *Command) Fail(msg ...interface{}) {
   if cmd.Program != nil {
     var see string
     if cmd.Options.Parsed() {
-      see = cmd.Program.Name + " " + cmd.Name + " -help"
+      see = cmd.Program.Name + " " + cmd.Path() + " " + cmd.Program.dash("help")
     } else {
-      see = cmd.Program.Name + " -help"
+      see = cmd.Program.Name + " " + cmd.Program.dash("help")
     }
     fmt.Fprintf(os.Stderr,
-      "%s %s: %v. See '%s'\n",
-      cmd.Program.Name, cmd.Name, fmt.Sprint(msg...), see)
+      "%s %s: %s\n",
+      cmd.Program.Name, cmd.Path(), seeHelp(fmt.Sprint(msg...), see))
   } else {
     fmt.Fprint(os.Stderr, msg...)
   }
+  if cmd.Program != nil && cmd.Program.inShell {
+    runtime.Goexit() // ends the command but not the shell; see runShellLine
+  }
   os.Exit(1)
 }
 
 
+// Prints a warning about the command to stderr
+func (cmd *Command) warnf(format string, a ...interface{}) {
+  // A command without a name represents the program itself (Program.SetOptions)
+  name := cmd.Path()
+  if cmd.Program != nil {
+    name = strings.TrimSpace(cmd.Program.Name + " " + name)
+  }
+  fmt.Fprintf(os.Stderr, "%s: warning: " + format + "\n", append([]interface{}{name}, a...)...)
+}
+
+
+// Reports an error from parsing the command's arguments. Usage is printed for flag.ErrHelp and
+// option values for -show-config.
+// Exits the process unless the command's program has ExitOnError set to false.
+func (cmd *Command) parseError(err error) {
+  exit := cmd.Program == nil || cmd.Program.ExitOnError
+  if err == flag.ErrHelp {
+    cmd.Usage()
+    if exit {
+      os.Exit(0)
+    }
+    return
+  }
+  if err == errShowConfig {
+    cmd.ShowConfig(os.Stdout)
+    if exit {
+      os.Exit(0)
+    }
+    return
+  }
+  if cmd.Program != nil {
+    see := cmd.Program.Name + " " + cmd.Path() + " " + cmd.Program.dash("help")
+    fmt.Fprintf(os.Stderr, "%s %s: %s\n", cmd.Program.Name, cmd.Path(), seeHelp(err.Error(), see))
+  } else {
+    fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.Path(), err)
+  }
+  if exit {
+    os.Exit(2)
+  }
+}
+
+
 func (cmd *Command) Usage() {
   if len(cmd.Description) != 0 {
     os.Stderr.WriteString(cmd.Description + "\n")
@@ -92,12 +165,25 @@ func (cmd *Command) Usage() {
     os.Stderr.WriteString(cmd.Program.Name + " ")
   }
 
-  if cmd.OptionCount != 0 {
-    fmt.Fprintf(os.Stderr, "%s [options]%s\n", cmd.Name, cmd.argsString())
-    os.Stderr.WriteString("Options:\n")
-    optionsUsage(cmd.Options)
+  showConfig := cmd.Program.showConfigOption(cmd.Options)
+  if cmd.OptionCount != 0 || showConfig {
+    fmt.Fprintf(os.Stderr, "%s [options]%s\n", cmd.Path(), cmd.argsString())
+    if cmd.countInheritedOptions() < cmd.OptionCount || showConfig {
+      os.Stderr.WriteString("Options:\n")
+      optionsUsage(cmd.Program, cmd.Options, cmd.options, false)
+    }
+    if cmd.countInheritedOptions() != 0 {
+      os.Stderr.WriteString("Inherited options:\n")
+      optionsUsage(cmd.Program, cmd.Options, cmd.options, true)
+    }
   } else {
-    fmt.Fprintf(os.Stderr, "%s%s\n", cmd.Name, cmd.argsString())
+    fmt.Fprintf(os.Stderr, "%s%s\n", cmd.Path(), cmd.argsString())
+  }
+
+  if len(cmd.Commands) != 0 {
+    w := newTabWriter()
+    cmd.Program.commandsUsage(w, cmd.Commands, cmd.cmdOrder, "")
+    w.Flush()
   }
 
   if len(cmd.Args) != 0 || cmd.VarArgs != nil {
@@ -131,8 +217,82 @@ func (cmd *Command) NameAndArgs() string {
 }
 
 
+// Writes a line describing the command for a list of commands
+func (cmd *Command) usageLine(w io.Writer) {
+  descr := cmd.Description
+  if cmd.Deprecated != "" {
+    descr += " (deprecated)"
+  }
+  fmt.Fprintf(w, "  %s%s\t%s\n", cmd.nameAndAliases(), cmd.argsString(), descr)
+}
+
+
+func (cmd *Command) nameAndAliases() string {
+  if len(cmd.Aliases) == 0 {
+    return cmd.Name
+  }
+  return cmd.Name + " (" + strings.Join(cmd.Aliases, ", ") + ")"
+}
+
+
+// Returns the names of the command and its parents, separated by spaces, e.g. "remote add"
+func (cmd *Command) Path() string {
+  if cmd.Parent != nil {
+    return cmd.Parent.Path() + " " + cmd.Name
+  }
+  return cmd.Name
+}
+
+
+// Returns a copy of cmd with copies of its subcommands, which can be added to another command
+// without changing the Parent of cmd's subcommands (Program.Mount)
+func (cmd *Command) clone() *Command {
+  c := *cmd
+  c.Parent = nil
+  c.cmdOrder = append([]string(nil), cmd.cmdOrder...)
+  if cmd.Commands != nil {
+    c.Commands = make(map[string]*Command, len(cmd.Commands))
+    for name, subcmd := range cmd.Commands {
+      subcmd = subcmd.clone()
+      subcmd.Parent = &c
+      c.Commands[name] = subcmd
+    }
+  }
+  return &c
+}
+
+
+// Add a subcommand to this command. If there's already a subcommand with the same name, that
+// subcommand is replaced with `subcmd`.
+func (cmd *Command) AddCommand(subcmd *Command) {
+  if cmd.Commands == nil {
+    cmd.Commands = make(map[string]*Command)
+  }
+  if cmd.Commands[subcmd.Name] == nil {
+    cmd.cmdOrder = append(cmd.cmdOrder, subcmd.Name)
+  }
+  subcmd.Parent = cmd
+  cmd.Commands[subcmd.Name] = subcmd
+}
+
+
+// Returns the names of the command's subcommands, sorted alphabetically or in the order they
+// were added when the program has KeepOrder set. Aliases are listed in parentheses after the
+// primary name, e.g. "list (ls)".
+func (cmd *Command) CommandNames() []string {
+  names := cmd.Program.orderedCommandNames(cmd.Commands, cmd.cmdOrder)
+  for i, name := range names {
+    names[i] = cmd.Commands[name].nameAndAliases()
+  }
+  return names
+}
+
+
 func (cmd *Command) argsString() string {
   var buf bytes.Buffer
+  if len(cmd.Commands) != 0 && cmd.main == nil {
+    buf.WriteString(" <command>")
+  }
   for _, arg := range cmd.Args {
     if arg.Optional {
       fmt.Fprintf(&buf, " [<%s>]", arg.Name)
@@ -151,10 +311,32 @@ func (cmd *Command) argsString() string {
 }
 
 
+// Parses options and arguments in args into the command's options and arguments. Response files
+// are not expanded; Program.Parse expands them in the arguments it returns for the command.
 func (cmd *Command) Parse(args []string) error {
-  if err := cmd.Options.Parse(args); err != nil {
+  if cmd.rawArgs {
+    // arguments are passed on as-is, e.g. to a shell alias
+    return cmd.Options.Parse(append([]string{"--"}, args...))
+  }
+  envSources, err := cmd.parseEnvArgs()
+  if err != nil {
+    return err
+  }
+  interspersed := cmd.Program != nil && cmd.Program.Interspersed
+  err = parseOptions(cmd.Program, cmd.Options, cmd.options, args, interspersed)
+  showConfig := err == errShowConfig
+  if err != nil && !showConfig {
+    return err
+  }
+  sources, err := cmd.Program.setUnsetOptions(
+    cmd.Options, cmd.options, configKeyPrefix(cmd), envSources)
+  if err != nil {
     return err
   }
+  cmd.sources = sources
+  if showConfig {
+    return errShowConfig
+  }
   args = cmd.Options.Args()
   argVarCount := len(cmd.Args)
   argEnd := argVarCount
@@ -182,12 +364,21 @@ func (cmd *Command) Parse(args []string) error {
 }
 
 
+// Parses args and runs the command as part of program p. Like Parse, it expects args as
+// returned by Program.Parse, with response files already expanded.
 func (cmd *Command) Run(p *Program, args []string) {
   if cmd.main != nil {
     pp := cmd.Program
     cmd.Program = p
     defer func(){ cmd.Program = pp }()
-    cmd.Parse(args)
+    defer cmd.inheritOptions(p)()
+    if err := cmd.Parse(args); err != nil {
+      cmd.parseError(err)
+      return
+    }
+    if cmd.Deprecated != "" {
+      cmd.warnf("command is deprecated; %s", cmd.Deprecated)
+    }
     cmd.main(cmd)
   }
 }
@@ -195,20 +386,90 @@ func (cmd *Command) Run(p *Program, args []string) {
 
 func (cmd *Command) countOptions() {
   cmd.OptionCount = 0
-  cmd.Options.VisitAll(func(f *flag.Flag) { cmd.OptionCount++ })
+  cmd.Options.VisitAll(func(f *flag.Flag) {
+    if !cmd.options[f.Name].isHidden() {
+      cmd.OptionCount++
+    }
+  })
 }
 
 
-var commandType = reflect.TypeOf(new(Command)).Elem()
+func (cmd *Command) countInheritedOptions() int {
+  n := 0
+  for _, info := range cmd.options {
+    if info.inherited && !info.hidden {
+      n++
+    }
+  }
+  return n
+}
 
 
-func NewCommand(name, description string, f interface{}) *Command {
-  fnv := reflect.ValueOf(f)
-  fnt := fnv.Type()
-  if fnt.Kind() != reflect.Func {
-    panic("not a function")
+// Replaces the options of cmd with a copy, to which the options cmd inherits are added: those of
+// programs mounted at cmd or above it (Program.Mount) and, when p has PersistentOptions set, those
+// of p. Options are then parsed into the copy rather than into cmd's own FlagSet, which may be
+// shared by several programs, like HelpCommand's is. Returns a function which restores cmd's own
+// options.
+func (cmd *Command) inheritOptions(p *Program) (restore func()) {
+  own, ownOptions, ownCount := cmd.Options, cmd.options, cmd.OptionCount
+  cmd.Options, cmd.options = cmd.optionsCopy(p)
+  cmd.countOptions()
+  return func() {
+    cmd.Options, cmd.options, cmd.OptionCount = own, ownOptions, ownCount
   }
+}
+
 
+// Returns a copy of cmd's options including the options cmd inherits (see inheritOptions).
+// Options with the same names as others closer to cmd are left out. The options of a program
+// mounted at cmd itself are not marked as inherited.
+func (cmd *Command) optionsCopy(p *Program) (*flag.FlagSet, map[string]*optionInfo) {
+  fs := flag.NewFlagSet(cmd.Options.Name(), flag.ContinueOnError)
+  fs.Usage = cmd.Options.Usage
+  options := make(map[string]*optionInfo, len(cmd.options))
+  for name, info := range cmd.options {
+    options[name] = info
+  }
+  cmd.Options.VisitAll(func(f *flag.Flag) { copyFlag(fs, f) })
+  inherit := func(from *flag.FlagSet, fromOptions map[string]*optionInfo, inherited bool) {
+    from.VisitAll(func(f *flag.Flag) {
+      if fs.Lookup(f.Name) != nil {
+        return
+      }
+      copyFlag(fs, f)
+      info := &optionInfo{}
+      if finfo := fromOptions[f.Name]; finfo != nil {
+        *info = *finfo
+      }
+      info.inherited = inherited
+      options[f.Name] = info
+    })
+  }
+  for c := cmd; c != nil; c = c.Parent {
+    if c.mounted != nil && c.mounted.Options != nil {
+      inherit(c.mounted.Options, c.mounted.options, c != cmd)
+    }
+  }
+  if p != nil && p.PersistentOptions && p.Options != nil {
+    inherit(p.Options, p.options, true)
+  }
+  return fs, options
+}
+
+
+// Defines an option in fs which shares its value with f
+func copyFlag(fs *flag.FlagSet, f *flag.Flag) {
+  fs.Var(f.Value, f.Name, f.Usage)
+  fs.Lookup(f.Name).DefValue = f.DefValue
+}
+
+
+var commandType = reflect.TypeOf(new(Command)).Elem()
+
+
+// Creates a new command which runs `f`. `f` can be nil for commands that only group
+// subcommands added with AddCommand.
+func NewCommand(name, description string, f interface{}) *Command {
   cmd := &Command{
     Name:        name,
     Description: description,
@@ -216,6 +477,16 @@ func NewCommand(name, description string, f interface{}) *Command {
   }
   cmd.Options.Usage = func() { cmd.Usage() }
 
+  if f == nil {
+    return cmd
+  }
+
+  fnv := reflect.ValueOf(f)
+  fnt := fnv.Type()
+  if fnt.Kind() != reflect.Func {
+    panic("not a function")
+  }
+
   if fnt.NumIn() == 0 {
     // taking no arguments and having no options
     if fn, ok := f.(func()); ok {
@@ -230,30 +501,56 @@ func NewCommand(name, description string, f interface{}) *Command {
     panic("Command.Options must be a struct")
   }
 
-  // accepts command as a second argument?
-  // if fnt.NumIn() == 2 {
-  //   T2 := fnt.In(0).Elem()
-  //   if T2 == commandType {
-  //     println("TODO T2 is commandType")
-  //   }
-  // }
-
   cmdStructVPtr := reflect.New(T)
   cmd.main = func(cmd *Command) {
-    fnv.Call([]reflect.Value{cmdStructVPtr, reflect.ValueOf(cmd)})
+    // Any parameters after the options struct are a *Command or program options (SetOptions)
+    args := []reflect.Value{cmdStructVPtr}
+    for i := 1; i != fnt.NumIn(); i++ {
+      args = append(args, cmd.paramValue(fnt.In(i)))
+    }
+    fnv.Call(args)
+  }
+
+  cmd.bindStruct(cmdStructVPtr)
+  cmd.countOptions()
+
+  // Default values of options and arguments, restored between runs in a shell (ShellCommand)
+  initial := reflect.New(T).Elem()
+  initial.Set(cmdStructVPtr.Elem())
+  cmd.reset = func() { cmdStructVPtr.Elem().Set(initial) }
+
+  return cmd
+}
+
+
+// Returns the value of type T to pass to the command's function
+func (cmd *Command) paramValue(T reflect.Type) reflect.Value {
+  if T == reflect.PtrTo(commandType) {
+    return reflect.ValueOf(cmd)
   }
+  for c := cmd; c != nil; c = c.Parent {
+    if c.mounted != nil && c.mounted.optionsValue.IsValid() && c.mounted.optionsValue.Type() == T {
+      return c.mounted.optionsValue
+    }
+  }
+  if cmd.Program != nil && cmd.Program.optionsValue.IsValid() &&
+     cmd.Program.optionsValue.Type() == T {
+    return cmd.Program.optionsValue
+  }
+  panic(fmt.Sprintf("no value for command function parameter of type %v", T))
+}
+
 
+// Binds the exported fields of the struct pointed to by stValuePtr as options and arguments
+func (cmd *Command) bindStruct(stValuePtr reflect.Value) {
+  T := stValuePtr.Elem().Type()
   for fieldIndex := 0; fieldIndex != T.NumField(); fieldIndex++ {
     field := T.Field(fieldIndex)
     rune0, _ := utf8.DecodeRuneInString(field.Name)
     if unicode.IsUpper(rune0) {
-      cmd.processField(&cmdStructVPtr, fieldIndex)
+      cmd.processField(&stValuePtr, fieldIndex)
     }
   }
-
-  cmd.countOptions()
-
-  return cmd
 }
 
 
@@ -261,7 +558,8 @@ func (cmd *Command) processField(stValuePtr *reflect.Value, fieldIndex int) {
   fieldV := stValuePtr.Elem().Field(fieldIndex)
   field := stValuePtr.Elem().Type().Field(fieldIndex)
   name := translateFieldName(field.Name)
-  defaultValue, descr, prefix := parseFieldTag(string(field.Tag))
+  keys, tag := parseTagKeys(string(field.Tag))
+  defaultValue, descr, prefix := parseFieldTag(tag)
   if prefix == '!' {
     optional := false
     cmd.addArg(&fieldV, optional, name, defaultValue, descr)
@@ -269,7 +567,7 @@ func (cmd *Command) processField(stValuePtr *reflect.Value, fieldIndex int) {
     optional := true
     cmd.addArg(&fieldV, optional, name, defaultValue, descr)
   } else {
-    cmd.addOption(&fieldV, name, defaultValue, descr)
+    cmd.addOption(&fieldV, name, defaultValue, descr, keys)
   }
 }
 
@@ -291,24 +589,80 @@ func (cmd *Command) addArg(field *reflect.Value, optional bool, name, defaultVal
 }
 
 
-func (cmd *Command) addOption(field *reflect.Value, name, defaultValue, descr string) {
+func (cmd *Command) addOption(
+  field *reflect.Value, name, defaultValue, descr string, keys map[string]string,
+) {
   // fmt.Printf("addOption(field=%v, name=%q, defaultValue=%q descr=%q)\n",
   //            field, name, defaultValue, descr)
   val := NewValueBinding(field, defaultValue)
-  if val != nil {
-    cmd.Options.Var(val, name, descr)
+  if val == nil {
+    return
+  }
+  cmd.Options.Var(val, name, descr)
+  info := cmd.optionInfo(name)
+  info.hidden = keys["hidden"] == "true"
+  info.argName = keys["arg"]
+  info.noNegate = keys["negatable"] == "false"
+  info.env = keys["env"]
+  info.typ = field.Type()
+  if short := keys["short"]; short != "" {
+    if utf8.RuneCountInString(short) != 1 {
+      panic("short option name must be a single character")
+    }
+    info.short = short
+  }
+  if keys["deprecated"] != "" {
+    // Old names of the option, accepted with a warning
+    for _, oldName := range strings.Split(keys["deprecated"], ",") {
+      oldName = strings.TrimSpace(oldName)
+      cmd.Options.Var(&deprecatedValue{val, cmd, oldName, name}, oldName, descr)
+      cmd.optionInfo(oldName).hidden = true
+    }
   }
 }
 
+
+// Returns information on the option `name`, creating it if needed
+func (cmd *Command) optionInfo(name string) *optionInfo {
+  if cmd.options == nil {
+    cmd.options = make(map[string]*optionInfo)
+  }
+  info := cmd.options[name]
+  if info == nil {
+    info = &optionInfo{}
+    cmd.options[name] = info
+  }
+  return info
+}
+
 // ===============================================================================================
 
 func NewValueBinding(v *reflect.Value, defaultValue string) ValueBinding {
   var val ValueBinding
-  switch v.Type().Kind() {
-    case reflect.Bool:   val = (*boolValue)(v)
-    case reflect.String: val = (*stringValue)(v)
-    case reflect.Slice:  val = (*sliceValue)(v)
-    default: return nil
+  if v.CanAddr() {
+    // types implementing ValueBinding themselves, like TriState
+    if vb, ok := v.Addr().Interface().(ValueBinding); ok {
+      if defaultValue != "" {
+        vb.Set(defaultValue)
+      }
+      return vb
+    }
+  }
+  if v.Type() == durationType {
+    val = (*durationValue)(v)
+  } else {
+    switch v.Type().Kind() {
+      case reflect.Bool:   val = (*boolValue)(v)
+      case reflect.String: val = (*stringValue)(v)
+      case reflect.Slice:  val = (*sliceValue)(v)
+      case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        val = (*intValue)(v)
+      case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        val = (*uintValue)(v)
+      case reflect.Float32, reflect.Float64:
+        val = (*floatValue)(v)
+      default: return nil
+    }
   }
   if defaultValue != "" {
     val.Set(defaultValue)
@@ -320,10 +674,19 @@ func NewValueBinding(v *reflect.Value, defaultValue string) ValueBinding {
 type ValueBinder func(*reflect.Value) ValueBinding
 
 func NewValueBinder(T reflect.Type) ValueBinder {
+  if T == durationType {
+    return func(v *reflect.Value) ValueBinding { return (*durationValue)(v) }
+  }
   switch T.Kind() {
   case reflect.String: return func(v *reflect.Value) ValueBinding { return (*stringValue)(v) }
   case reflect.Bool: return func(v *reflect.Value) ValueBinding { return (*boolValue)(v) }
   case reflect.Slice: return func(v *reflect.Value) ValueBinding { return (*sliceValue)(v) }
+  case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+    return func(v *reflect.Value) ValueBinding { return (*intValue)(v) }
+  case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+    return func(v *reflect.Value) ValueBinding { return (*uintValue)(v) }
+  case reflect.Float32, reflect.Float64:
+    return func(v *reflect.Value) ValueBinding { return (*floatValue)(v) }
   default: panic("unexpected value type")
   }
 }
@@ -355,10 +718,94 @@ func (v *boolValue) Set(s string) error {
 }
 
 
+type intValue reflect.Value
+func (v *intValue) rv() *reflect.Value { return (*reflect.Value)(v) }
+func (v *intValue) String() string { return strconv.FormatInt(v.rv().Int(), 10) }
+func (v *intValue) Set(s string) error {
+  n, err := strconv.ParseInt(s, 0, v.rv().Type().Bits())
+  if err != nil {
+    return numError(err)
+  }
+  v.rv().SetInt(n)
+  return nil
+}
+
+
+type uintValue reflect.Value
+func (v *uintValue) rv() *reflect.Value { return (*reflect.Value)(v) }
+func (v *uintValue) String() string { return strconv.FormatUint(v.rv().Uint(), 10) }
+func (v *uintValue) Set(s string) error {
+  n, err := strconv.ParseUint(s, 0, v.rv().Type().Bits())
+  if err != nil {
+    return numError(err)
+  }
+  v.rv().SetUint(n)
+  return nil
+}
+
+
+type floatValue reflect.Value
+func (v *floatValue) rv() *reflect.Value { return (*reflect.Value)(v) }
+func (v *floatValue) String() string {
+  return strconv.FormatFloat(v.rv().Float(), 'g', -1, v.rv().Type().Bits())
+}
+func (v *floatValue) Set(s string) error {
+  f, err := strconv.ParseFloat(s, v.rv().Type().Bits())
+  if err != nil {
+    return numError(err)
+  }
+  v.rv().SetFloat(f)
+  return nil
+}
+
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+type durationValue reflect.Value
+func (v *durationValue) rv() *reflect.Value { return (*reflect.Value)(v) }
+func (v *durationValue) String() string { return time.Duration(v.rv().Int()).String() }
+func (v *durationValue) Set(s string) error {
+  d, err := time.ParseDuration(s)
+  if err != nil {
+    return errors.New("invalid duration")
+  }
+  v.rv().SetInt(int64(d))
+  return nil
+}
+
+
+// Simplifies errors from strconv, which repeat the input, to e.g. "value out of range"
+func numError(err error) error {
+  if ne, ok := err.(*strconv.NumError); ok {
+    if ne.Err == strconv.ErrSyntax {
+      return errors.New("invalid number")
+    }
+    return ne.Err
+  }
+  return err
+}
+
+
 type sliceValue reflect.Value
 func (sv *sliceValue) rv() *reflect.Value { return (*reflect.Value)(sv) }
-func (sv *sliceValue) String() string { return sv.rv().String() }
-func (sv *sliceValue) Set(s string) error { panic("Set() on slice") }
+func (sv *sliceValue) String() string {
+  v := sv.rv()
+  valueBinder := NewValueBinder(v.Type().Elem())
+  elems := make([]string, v.Len())
+  for i := range elems {
+    elem := v.Index(i)
+    elems[i] = valueBinder(&elem).String()
+  }
+  return strings.Join(elems, ",")
+}
+// Sets the slice from a comma-separated list, e.g. "a,b", as given by an environment variable or
+// a config file
+func (sv *sliceValue) Set(s string) error {
+  if s == "" {
+    return sv.Setv(nil)
+  }
+  return sv.Setv(strings.Split(s, ","))
+}
 func (sv *sliceValue) Setv(args []string) error {
   v := sv.rv()
   T := v.Type()
@@ -376,6 +823,43 @@ func (sv *sliceValue) Setv(args []string) error {
 
 // ===============================================================================================
 
+// Parses leading key:"value" pairs of a field tag, e.g. `hidden:"true" ="x" Some option`,
+// returning the pairs and the rest of the tag. If there are no such pairs, tag is returned as-is.
+func parseTagKeys(tag string) (keys map[string]string, tail string) {
+  tail = tag
+  for {
+    s := strings.TrimLeft(tail, " ")
+    i := 0
+    for i < len(s) && s[i] >= 'a' && s[i] <= 'z' {
+      i++
+    }
+    if i == 0 || i + 1 >= len(s) || s[i] != ':' || s[i+1] != '"' {
+      break
+    }
+    j := i + 2
+    for j < len(s) && s[j] != '"' {
+      if s[j] == '\\' {
+        j++
+      }
+      j++
+    }
+    if j >= len(s) {
+      break
+    }
+    value, err := strconv.Unquote(s[i+1:j+1])
+    if err != nil {
+      break
+    }
+    if keys == nil {
+      keys = make(map[string]string)
+    }
+    keys[s[:i]] = value
+    tail = strings.TrimLeft(s[j+1:], " ")
+  }
+  return keys, tail
+}
+
+
 func parseFieldTag(tag string) (defaultValue string, tail string, prefix byte) {
   tail = tag
   for tag != "" {
@@ -0,0 +1,323 @@
+package cmdr
+import (
+  "bytes"
+  "encoding/json"
+  "errors"
+  "flag"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+
+// A value read from a config file
+type ConfigValue struct {
+  Key   string // e.g. "build.jobs" for the -jobs option of the "build" command
+  Value string
+  Line  int    // line in the file where the value is defined, or 0 if unknown
+}
+
+// Decodes the contents of config files of some format
+type ConfigDecoder interface {
+  DecodeConfig(data []byte) ([]ConfigValue, error)
+}
+
+
+var configDecoders = map[string]ConfigDecoder{
+  ".json": JSONConfigDecoder{},
+}
+
+// Registers a decoder for config files with the filename extension `ext`, e.g. ".toml"
+func RegisterConfigDecoder(ext string, dec ConfigDecoder) {
+  configDecoders[ext] = dec
+}
+
+
+// A config file which has been loaded
+type configFile struct {
+  path   string
+  values []ConfigValue
+}
+
+// Config values of a program, loaded from files
+type config struct {
+  files  []*configFile          // in order of increasing precedence
+  values map[string]configEntry // effective value of each key
+}
+
+type configEntry struct {
+  value string
+  file  *configFile
+  line  int
+}
+
+
+// Returns the paths of the program's config files which exist, in order of increasing
+// precedence: system (/etc/<name>/config.<ext>), user ($XDG_CONFIG_HOME/<name>/config.<ext>) and
+// project (.<name>.<ext> in the working directory or the closest of its parents).
+func (p *Program) configPaths() []string {
+  var paths []string
+  exts := configExts()
+
+  addFirst := func(candidates ...string) bool {
+    for _, path := range candidates {
+      if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+        paths = append(paths, path)
+        return true
+      }
+    }
+    return false
+  }
+  withExts := func(base string) []string {
+    var candidates []string
+    for _, ext := range exts {
+      candidates = append(candidates, base + ext)
+    }
+    return candidates
+  }
+
+  addFirst(withExts(filepath.Join("/etc", p.ConfigName, "config"))...)
+  if dir := userConfigDir(); dir != "" {
+    addFirst(withExts(filepath.Join(dir, p.ConfigName, "config"))...)
+  }
+  if dir, err := os.Getwd(); err == nil {
+    for {
+      if addFirst(withExts(filepath.Join(dir, "." + p.ConfigName))...) {
+        break
+      }
+      parent := filepath.Dir(dir)
+      if parent == dir {
+        break
+      }
+      dir = parent
+    }
+  }
+  return paths
+}
+
+
+// Returns the file extensions of the registered config decoders, in the order config files are
+// looked for
+func configExts() []string {
+  exts := make([]string, 0, len(configDecoders))
+  for ext := range configDecoders {
+    exts = append(exts, ext)
+  }
+  sort.Strings(exts)
+  return exts
+}
+
+
+// Returns $XDG_CONFIG_HOME, defaulting to ~/.config
+func userConfigDir() string {
+  if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+    return dir
+  }
+  if home := os.Getenv("HOME"); home != "" {
+    return filepath.Join(home, ".config")
+  }
+  return ""
+}
+
+
+// Returns the program's config, loading it if needed. Returns nil if ConfigName is empty.
+func (p *Program) loadConfig() (*config, error) {
+  if p == nil || p.ConfigName == "" {
+    return nil, nil
+  }
+  if p.config != nil {
+    return p.config, nil
+  }
+  c := &config{values: make(map[string]configEntry)}
+  for _, path := range p.configPaths() {
+    f, err := loadConfigFile(path)
+    if err != nil {
+      return nil, err
+    }
+    c.files = append(c.files, f)
+    for _, v := range f.values {
+      c.values[v.Key] = configEntry{v.Value, f, v.Line}
+    }
+  }
+  p.config = c
+  return c, nil
+}
+
+
+func loadConfigFile(path string) (*configFile, error) {
+  dec := configDecoders[filepath.Ext(path)]
+  if dec == nil {
+    return nil, fmt.Errorf("%s: unsupported config file format", path)
+  }
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  values, err := dec.DecodeConfig(data)
+  if err != nil {
+    return nil, fmt.Errorf("%s: %v", path, err)
+  }
+  return &configFile{path, values}, nil
+}
+
+
+// Sets the options of fs which were not given as arguments from the selected profile, from
+// environment variables or from config values, in that order of precedence. Profile and config
+// values have keys `keyPrefix` + the option's name.
+// Options inherited from the program (PersistentOptions) are left alone, as are deprecated names.
+// Options in `preset` were set otherwise and are left alone too.
+// Returns where the value of each option which isn't at its default came from.
+func (p *Program) setUnsetOptions(
+  fs *flag.FlagSet, options map[string]*optionInfo, keyPrefix string,
+  preset map[string]ValueSource,
+) (map[string]ValueSource, error) {
+  c, err := p.loadConfig()
+  if err != nil {
+    return nil, err
+  }
+  profile, err := p.profileValues()
+  if err != nil {
+    return nil, err
+  }
+  sources := make(map[string]ValueSource)
+  for name, source := range preset {
+    sources[name] = source
+  }
+  fs.Visit(func(f *flag.Flag) {
+    if dv, ok := f.Value.(*deprecatedValue); ok {
+      sources[dv.replacement] = ValueSource{Kind: SourceArgs}
+    }
+    sources[f.Name] = ValueSource{Kind: SourceArgs}
+  })
+  fs.VisitAll(func(f *flag.Flag) {
+    info := options[f.Name]
+    if _, given := sources[f.Name]; given || err != nil || (info != nil && info.inherited) {
+      return
+    }
+    if _, ok := f.Value.(*deprecatedValue); ok {
+      return
+    }
+    if pv, ok := profile[keyPrefix + f.Name]; ok {
+      if e := f.Value.Set(pv.value); e != nil {
+        err = fmt.Errorf("invalid value %q for option %s from profile %s: %v",
+                         pv.value, p.dash(f.Name), pv.profile, e)
+      }
+      sources[f.Name] = ValueSource{Kind: SourceProfile, Name: pv.profile}
+      return
+    }
+    if name := p.optionEnv(f.Name, info); name != "" {
+      if value, ok := os.LookupEnv(name); ok {
+        if e := f.Value.Set(value); e != nil {
+          err = fmt.Errorf("invalid value %q for option %s from $%s: %v",
+                           value, p.dash(f.Name), name, e)
+        }
+        sources[f.Name] = ValueSource{Kind: SourceEnv, Name: name}
+        return
+      }
+    }
+    if c != nil {
+      if entry, ok := c.values[keyPrefix + f.Name]; ok {
+        if e := f.Value.Set(entry.value); e != nil {
+          err = fmt.Errorf("invalid value %q for option %s from %s:%d: %v",
+                           entry.value, p.dash(f.Name), entry.file.path, entry.line, e)
+        }
+        sources[f.Name] = ValueSource{Kind: SourceConfig, Name: entry.file.path, Line: entry.line}
+      }
+    }
+  })
+  return sources, err
+}
+
+
+// Returns the prefix of config keys for the options of cmd, e.g. "remote.add."
+func configKeyPrefix(cmd *Command) string {
+  return strings.Replace(cmd.Path(), " ", ".", -1) + "."
+}
+
+// ===============================================================================================
+
+// Decodes JSON config files. Nested objects are flattened into dotted keys, so that
+// {"build": {"jobs": 8}} and {"build.jobs": 8} are equivalent. Arrays of strings, numbers or
+// booleans become comma-separated values, so their elements can't contain commas.
+type JSONConfigDecoder struct {}
+
+func (JSONConfigDecoder) DecodeConfig(data []byte) ([]ConfigValue, error) {
+  dec := json.NewDecoder(bytes.NewReader(data))
+  dec.UseNumber()
+  t, err := dec.Token()
+  if err != nil {
+    return nil, err
+  }
+  if t != json.Delim('{') {
+    return nil, errors.New("expected a JSON object")
+  }
+  var values []ConfigValue
+  if err := decodeJSONObject(dec, data, "", &values); err != nil {
+    return nil, err
+  }
+  return values, nil
+}
+
+
+func decodeJSONObject(
+  dec *json.Decoder, data []byte, keyPrefix string, values *[]ConfigValue,
+) error {
+  for dec.More() {
+    t, err := dec.Token()
+    if err != nil {
+      return err
+    }
+    key := keyPrefix + t.(string)
+    line := 1 + bytes.Count(data[:dec.InputOffset()], []byte("\n"))
+    if t, err = dec.Token(); err != nil {
+      return err
+    }
+    switch t {
+    case json.Delim('{'):
+      if err := decodeJSONObject(dec, data, key + ".", values); err != nil {
+        return err
+      }
+      continue
+    case json.Delim('['):
+      var elems []string
+      for dec.More() {
+        if t, err = dec.Token(); err != nil {
+          return err
+        }
+        s, ok := jsonScalarString(t)
+        if !ok {
+          return fmt.Errorf("line %d: %s: unsupported value", line, key)
+        }
+        if strings.IndexByte(s, ',') != -1 {
+          return fmt.Errorf("line %d: %s: array elements can't contain commas", line, key)
+        }
+        elems = append(elems, s)
+      }
+      if _, err = dec.Token(); err != nil {
+        return err
+      }
+      *values = append(*values, ConfigValue{key, strings.Join(elems, ","), line})
+      continue
+    case nil:
+      continue
+    }
+    s, _ := jsonScalarString(t)
+    *values = append(*values, ConfigValue{key, s, line})
+  }
+  _, err := dec.Token() // '}'
+  return err
+}
+
+
+func jsonScalarString(t json.Token) (string, bool) {
+  switch v := t.(type) {
+  case string:      return v, true
+  case json.Number: return v.String(), true
+  case bool:        return strconv.FormatBool(v), true
+  }
+  return "", false
+}
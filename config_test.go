@@ -0,0 +1,84 @@
+package cmdr
+import (
+  "flag"
+  "os"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+
+func TestJSONConfigDecoder(t *testing.T) {
+  tests := []struct {
+    json   string
+    values []ConfigValue
+    err    string // start of the error message
+  }{
+    {`{}`, nil, ""},
+    {`{"verbose": true, "name": "x"}`,
+     []ConfigValue{{"verbose", "true", 1}, {"name", "x", 1}}, ""},
+    {"{\n  \"build\": {\n    \"jobs\": 8\n  },\n  \"remote.add.fetch\": false\n}",
+     []ConfigValue{{"build.jobs", "8", 3}, {"remote.add.fetch", "false", 5}}, ""},
+    {`{"a": {"b": {"c": 1.5}}}`, []ConfigValue{{"a.b.c", "1.5", 1}}, ""},
+    {`{"tags": ["a", 2, true], "empty": []}`,
+     []ConfigValue{{"tags", "a,2,true", 1}, {"empty", "", 1}}, ""},
+    {`{"x": null}`, nil, ""},
+    {`{"tags": ["a,b"]}`, nil, "line 1: tags: array elements can't contain commas"},
+    {`{"tags": [["a"]]}`, nil, "line 1: tags: unsupported value"},
+    {`{"tags": [{}]}`, nil, "line 1: tags: unsupported value"},
+    {`[]`, nil, "expected a JSON object"},
+    {`{"a": `, nil, "EOF"},
+  }
+  for _, test := range tests {
+    values, err := JSONConfigDecoder{}.DecodeConfig([]byte(test.json))
+    if test.err != "" {
+      if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+        t.Errorf("%q: error %v, want %q", test.json, err, test.err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("%q: unexpected error: %v", test.json, err)
+      continue
+    }
+    if (len(values) != 0 || len(test.values) != 0) && !reflect.DeepEqual(values, test.values) {
+      t.Errorf("%q:\n  got  %v\n  want %v", test.json, values, test.values)
+    }
+  }
+}
+
+
+func TestSliceOptionValues(t *testing.T) {
+  var opt struct {
+    Tags []string `Build tags`
+    Ids  []int    `IDs`
+  }
+  p := newTestProgram("tool")
+  p.ConfigName = "tool"
+  p.EnvPrefix = "CMDR_TEST_"
+  file := &configFile{path: "config.json"}
+  p.config = &config{values: map[string]configEntry{
+    "build.tags": {"a,b", file, 1},
+  }}
+  os.Setenv("CMDR_TEST_IDS", "1,2,3")
+  defer os.Unsetenv("CMDR_TEST_IDS")
+
+  cmd := &Command{Options: flag.NewFlagSet("build", flag.ContinueOnError), Program: p}
+  cmd.bindStruct(reflect.ValueOf(&opt))
+  if _, err := p.setUnsetOptions(cmd.Options, cmd.options, "build.", nil); err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(opt.Tags, []string{"a", "b"}) ||
+     !reflect.DeepEqual(opt.Ids, []int{1, 2, 3}) {
+    t.Errorf("tags %q and ids %v, want [a b] and [1 2 3]", opt.Tags, opt.Ids)
+  }
+  if s := cmd.Options.Lookup("tags").Value.String(); s != "a,b" {
+    t.Errorf("tags value is %q, want \"a,b\"", s)
+  }
+  if err := checkOptionValue(cmd.Options.Lookup("ids"), cmd.options["ids"], "1,x"); err == nil {
+    t.Errorf("checkOptionValue accepted \"1,x\" for a []int option")
+  }
+  if err := checkOptionValue(cmd.Options.Lookup("tags"), cmd.options["tags"], "x,y"); err != nil {
+    t.Errorf("checkOptionValue rejected \"x,y\" for a []string option: %v", err)
+  }
+}
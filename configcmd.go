@@ -0,0 +1,390 @@
+package cmdr
+import (
+  "bytes"
+  "encoding/json"
+  "errors"
+  "flag"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "sort"
+  "strings"
+  "text/tabwriter"
+)
+
+
+// A command that reads and writes the user's config file, e.g. "config set build.jobs 8"
+var ConfigCommand = NewCommand("config", "Get and set persistent option values", nil)
+
+func init() {
+  ConfigCommand.AddCommand(NewCommand("get", "Print the value of a config key", func (opt *struct {
+    Key string `! Config key, e.g. build.jobs`
+  }, cmd *Command) {
+    c := cmd.requireConfig()
+    key := cmd.Program.canonicalConfigKey(opt.Key)
+    entry, ok := c.values[key]
+    if !ok {
+      cmd.Fail(fmt.Sprintf("%q is not set", opt.Key))
+    }
+    fmt.Println(entry.value)
+  }))
+
+  ConfigCommand.AddCommand(NewCommand("set", "Set a value in the user's config file",
+  func (opt *struct {
+    Key   string `! Config key, e.g. build.jobs`
+    Value string `! Value to set`
+  }, cmd *Command) {
+    cmd.requireConfig()
+    key, f, info, err := cmd.Program.configOption(opt.Key)
+    if err != nil {
+      cmd.Fail(err)
+    }
+    if f != nil {
+      if err := checkOptionValue(f, info, opt.Value); err != nil {
+        cmd.Fail(fmt.Sprintf("invalid value %q for %s: %v", opt.Value, opt.Key, err))
+      }
+    }
+    cmd.editUserConfig(func(o *jsonObject) bool {
+      setJSONKey(o, key, configJSONValue(f, info, opt.Value))
+      return true
+    })
+  }))
+
+  ConfigCommand.AddCommand(NewCommand("unset", "Remove a value from the user's config file",
+  func (opt *struct {
+    Key string `! Config key, e.g. build.jobs`
+  }, cmd *Command) {
+    cmd.requireConfig()
+    key := cmd.Program.canonicalConfigKey(opt.Key)
+    cmd.editUserConfig(func(o *jsonObject) bool {
+      return unsetJSONKey(o, key)
+    })
+  }))
+
+  ConfigCommand.AddCommand(NewCommand("list", "List config values", func (opt *struct {
+    ShowOrigin bool `Show the file and line each value comes from`
+  }, cmd *Command) {
+    c := cmd.requireConfig()
+    keys := make([]string, 0, len(c.values))
+    for key := range c.values {
+      keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    w := tabwriter.NewWriter(os.Stdout, 5, 0, 2, ' ', 0)
+    for _, key := range keys {
+      entry := c.values[key]
+      if opt.ShowOrigin {
+        fmt.Fprintf(w, "%s:%d\t", entry.file.path, entry.line)
+      }
+      fmt.Fprintf(w, "%s=%s\n", key, entry.value)
+    }
+    w.Flush()
+  }))
+}
+
+
+// Returns the config of the command's program, failing if it has none
+func (cmd *Command) requireConfig() *config {
+  c, err := cmd.Program.loadConfig()
+  if err != nil {
+    cmd.Fail(err)
+  }
+  if c == nil {
+    cmd.Fail("the program has no config files")
+  }
+  return c
+}
+
+
+// Returns the path of the user's config file, e.g. ~/.config/tool/config.json. That's the file
+// configPaths finds in the user's config directory, or config.json if there's none yet.
+func (p *Program) userConfigPath() string {
+  dir := userConfigDir()
+  if dir == "" {
+    return ""
+  }
+  base := filepath.Join(dir, p.ConfigName, "config")
+  for _, ext := range configExts() {
+    if fi, err := os.Stat(base + ext); err == nil && !fi.IsDir() {
+      return base + ext
+    }
+  }
+  return base + ".json"
+}
+
+
+// Loads the user's config file, calls `edit` with its contents and writes the file if `edit`
+// returns true. Fails if `edit` returns false. Only JSON config files can be edited; the order
+// of their keys is kept.
+func (cmd *Command) editUserConfig(edit func(*jsonObject) bool) {
+  path := cmd.Program.userConfigPath()
+  if path == "" {
+    cmd.Fail("can't locate the user's config directory; $HOME is not set")
+  }
+  if _, ok := configDecoders[filepath.Ext(path)].(JSONConfigDecoder); !ok {
+    cmd.Fail(fmt.Sprintf("can't edit %s; only JSON config files can be edited", path))
+  }
+  o := newJSONObject()
+  data, err := ioutil.ReadFile(path)
+  if err == nil {
+    if err := json.Unmarshal(data, o); err != nil {
+      cmd.Fail(fmt.Sprintf("%s: %v", path, err))
+    }
+  } else if !os.IsNotExist(err) {
+    cmd.Fail(err)
+  }
+  if !edit(o) {
+    cmd.Fail(fmt.Sprintf("not set in %s", path))
+  }
+  data, err = json.MarshalIndent(o, "", "  ")
+  if err == nil {
+    err = os.MkdirAll(filepath.Dir(path), 0755)
+  }
+  if err == nil {
+    err = ioutil.WriteFile(path, append(data, '\n'), 0644)
+  }
+  if err != nil {
+    cmd.Fail(err)
+  }
+  cmd.Program.config = nil // reload on next use
+}
+
+
+// Finds the option a config key refers to, e.g. the -jobs option of the "build" command for
+// "build.jobs". Profile keys like "profile.ci.build.jobs" refer to the same options.
+// Returns the key with command aliases replaced by command names, as it's used in config files,
+// and a nil flag for keys which don't refer to an option, like "profile.ci.extends" and
+// "alias.st".
+func (p *Program) configOption(key string) (string, *flag.Flag, *optionInfo, error) {
+  parts := strings.Split(key, ".")
+  if len(parts) == 2 && parts[0] == "alias" {
+    return key, nil, nil, nil
+  }
+  prefix := ""
+  if len(parts) > 2 && parts[0] == "profile" {
+    prefix, parts = parts[0] + "." + parts[1] + ".", parts[2:]
+    if len(parts) == 1 && (parts[0] == "extends" || parts[0] == "description") {
+      return key, nil, nil, nil
+    }
+  }
+  fs, options, commands := p.Options, p.options, p.Commands
+  for i, name := range parts[:len(parts)-1] {
+    cmd, candidates := p.lookupCommand(commands, name)
+    if cmd == nil {
+      return "", nil, nil, fmt.Errorf("%s: %s", key, commandLookupError(commands, name, candidates))
+    }
+    parts[i] = cmd.Name
+    fs, options = cmd.optionsCopy(nil) // including the options of a mounted program
+    commands = cmd.Commands
+  }
+  name := parts[len(parts)-1]
+  f := fs.Lookup(name)
+  if f == nil || (options[name] != nil && options[name].inherited) {
+    return "", nil, nil, fmt.Errorf("%s: %v", key, unknownOptionError(p, fs, options, name))
+  }
+  if dv, ok := f.Value.(*deprecatedValue); ok {
+    return "", nil, nil, fmt.Errorf("%s: option %s is deprecated; use %s instead",
+                                    key, p.dash(name), p.dash(dv.replacement))
+  }
+  return prefix + strings.Join(parts, "."), f, options[name], nil
+}
+
+
+// Returns key as it's used in config files, with command aliases replaced by command names.
+// Keys which don't refer to an option are returned as is, so that they can still be read and
+// removed.
+func (p *Program) canonicalConfigKey(key string) string {
+  if k, _, _, err := p.configOption(key); err == nil {
+    return k
+  }
+  return key
+}
+
+
+// Returns an error if s is not a valid value for the option f
+func checkOptionValue(f *flag.Flag, info *optionInfo, s string) (err error) {
+  if info != nil && info.typ != nil {
+    v := reflect.New(info.typ).Elem()
+    if b := NewValueBinding(&v, ""); b != nil {
+      return b.Set(s)
+    }
+    return nil
+  }
+  // A flag.Value defined with the "flag" package. Set a new value of the same type, unless that
+  // doesn't work for the type, e.g. because its zero value holds a nil pointer.
+  defer func() {
+    if recover() != nil {
+      err = nil
+    }
+  }()
+  if t := reflect.TypeOf(f.Value); t.Kind() == reflect.Ptr {
+    if v, ok := reflect.New(t.Elem()).Interface().(flag.Value); ok {
+      return v.Set(s)
+    }
+  }
+  return nil
+}
+
+
+// Returns s as a JSON value of the type of the option f, e.g. a bool for a boolean option
+func configJSONValue(f *flag.Flag, info *optionInfo, s string) interface{} {
+  if f == nil {
+    return s
+  }
+  if isBoolFlag(f.Value) && (s == "true" || s == "false") {
+    return s == "true"
+  }
+  if info != nil && info.typ != nil && info.typ != durationType {
+    switch info.typ.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+         reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+         reflect.Float32, reflect.Float64:
+      if json.Valid([]byte(s)) {
+        return json.Number(s)
+      }
+    }
+  }
+  return s
+}
+
+
+// A JSON object which keeps the order of its keys, so that editing a config file doesn't
+// reorder it. Nested objects are *jsonObject values as well.
+type jsonObject struct {
+  keys   []string
+  values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+  return &jsonObject{values: make(map[string]interface{})}
+}
+
+// Sets the value of key, adding key last if it's new
+func (o *jsonObject) set(key string, value interface{}) {
+  if _, ok := o.values[key]; !ok {
+    o.keys = append(o.keys, key)
+  }
+  o.values[key] = value
+}
+
+func (o *jsonObject) delete(key string) {
+  if _, ok := o.values[key]; !ok {
+    return
+  }
+  delete(o.values, key)
+  for i, k := range o.keys {
+    if k == key {
+      o.keys = append(o.keys[:i], o.keys[i+1:]...)
+      break
+    }
+  }
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+  dec := json.NewDecoder(bytes.NewReader(data))
+  dec.UseNumber()
+  if t, err := dec.Token(); err != nil {
+    return err
+  } else if t != json.Delim('{') {
+    return errors.New("expected a JSON object")
+  }
+  for dec.More() {
+    t, err := dec.Token()
+    if err != nil {
+      return err
+    }
+    var raw json.RawMessage
+    if err := dec.Decode(&raw); err != nil {
+      return err
+    }
+    var value interface{}
+    if raw[0] == '{' {
+      sub := newJSONObject()
+      err = sub.UnmarshalJSON(raw)
+      value = sub
+    } else {
+      d := json.NewDecoder(bytes.NewReader(raw))
+      d.UseNumber()
+      err = d.Decode(&value)
+    }
+    if err != nil {
+      return err
+    }
+    o.set(t.(string), value)
+  }
+  _, err := dec.Token() // '}'
+  return err
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+  var buf bytes.Buffer
+  buf.WriteByte('{')
+  for i, key := range o.keys {
+    if i > 0 {
+      buf.WriteByte(',')
+    }
+    k, err := json.Marshal(key)
+    if err != nil {
+      return nil, err
+    }
+    v, err := json.Marshal(o.values[key])
+    if err != nil {
+      return nil, err
+    }
+    buf.Write(k)
+    buf.WriteByte(':')
+    buf.Write(v)
+  }
+  buf.WriteByte('}')
+  return buf.Bytes(), nil
+}
+
+
+// Sets `key` in a decoded JSON object, updating an existing value, whether it's nested, as in
+// {"build": {"jobs": 8}}, or not, as in {"build.jobs": 8}. New keys are added as nested objects.
+func setJSONKey(o *jsonObject, key string, value interface{}) {
+  if _, ok := o.values[key]; ok {
+    o.values[key] = value
+    return
+  }
+  for i := 0; i < len(key); i++ {
+    if key[i] == '.' {
+      if sub, ok := o.values[key[:i]].(*jsonObject); ok {
+        setJSONKey(sub, key[i+1:], value)
+        return
+      }
+    }
+  }
+  if i := strings.IndexByte(key, '.'); i != -1 {
+    if _, ok := o.values[key[:i]]; !ok {
+      sub := newJSONObject()
+      o.set(key[:i], sub)
+      setJSONKey(sub, key[i+1:], value)
+      return
+    }
+  }
+  o.set(key, value)
+}
+
+
+// Removes `key` from a decoded JSON object, along with objects left empty.
+// Returns false if there's no such key.
+func unsetJSONKey(o *jsonObject, key string) bool {
+  if _, ok := o.values[key]; ok {
+    o.delete(key)
+    return true
+  }
+  for i := 0; i < len(key); i++ {
+    if key[i] == '.' {
+      if sub, ok := o.values[key[:i]].(*jsonObject); ok && unsetJSONKey(sub, key[i+1:]) {
+        if len(sub.keys) == 0 {
+          o.delete(key[:i])
+        }
+        return true
+      }
+    }
+  }
+  return false
+}
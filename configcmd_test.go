@@ -0,0 +1,122 @@
+package cmdr
+import (
+  "encoding/json"
+  "strings"
+  "testing"
+)
+
+
+func TestSetJSONKey(t *testing.T) {
+  tests := []struct {
+    json  string
+    key   string
+    value interface{}
+    want  string
+  }{
+    {`{}`, "verbose", true, `{"verbose":true}`},
+    {`{"verbose":false}`, "verbose", true, `{"verbose":true}`},
+    {`{"z":1,"a":2}`, "m", 3, `{"z":1,"a":2,"m":3}`},
+    {`{}`, "build.jobs", 8, `{"build":{"jobs":8}}`},
+    {`{"build":{"jobs":1,"v":true}}`, "build.jobs", 8, `{"build":{"jobs":8,"v":true}}`},
+    {`{"build":{"v":true}}`, "build.jobs", 8, `{"build":{"v":true,"jobs":8}}`},
+    {`{"build.jobs":1}`, "build.jobs", 8, `{"build.jobs":8}`},
+    {`{"remote":{"add.fetch":false}}`, "remote.add.fetch", true,
+     `{"remote":{"add.fetch":true}}`},
+    {`{"remote.add":{"fetch":false}}`, "remote.add.fetch", true,
+     `{"remote.add":{"fetch":true}}`},
+    {`{"build":"x"}`, "build.jobs", 8, `{"build":"x","build.jobs":8}`},
+    {`{"n":1.50,"l":[1,"a"]}`, "x", "y", `{"n":1.50,"l":[1,"a"],"x":"y"}`},
+  }
+  for _, test := range tests {
+    o := newJSONObject()
+    if err := json.Unmarshal([]byte(test.json), o); err != nil {
+      t.Fatalf("%s: %v", test.json, err)
+    }
+    setJSONKey(o, test.key, test.value)
+    data, err := json.Marshal(o)
+    if err != nil {
+      t.Fatalf("%s: %v", test.json, err)
+    }
+    if string(data) != test.want {
+      t.Errorf("setJSONKey(%s, %q, %v) = %s, want %s",
+               test.json, test.key, test.value, data, test.want)
+    }
+  }
+}
+
+
+func TestUnsetJSONKey(t *testing.T) {
+  tests := []struct {
+    json  string
+    key   string
+    want  string // "" if the key is not found
+  }{
+    {`{"a":1,"verbose":true,"b":2}`, "verbose", `{"a":1,"b":2}`},
+    {`{"build":{"jobs":8,"v":true}}`, "build.jobs", `{"build":{"v":true}}`},
+    {`{"a":1,"build":{"jobs":8}}`, "build.jobs", `{"a":1}`},
+    {`{"build.jobs":8}`, "build.jobs", `{}`},
+    {`{"remote":{"add.fetch":true}}`, "remote.add.fetch", `{}`},
+    {`{"build":{"jobs":8}}`, "build.v", ""},
+    {`{"build":8}`, "build.jobs", ""},
+    {`{}`, "verbose", ""},
+  }
+  for _, test := range tests {
+    o := newJSONObject()
+    if err := json.Unmarshal([]byte(test.json), o); err != nil {
+      t.Fatalf("%s: %v", test.json, err)
+    }
+    found := unsetJSONKey(o, test.key)
+    if found != (test.want != "") {
+      t.Errorf("unsetJSONKey(%s, %q) = %v, want %v", test.json, test.key, found, !found)
+      continue
+    }
+    data, err := json.Marshal(o)
+    if err != nil {
+      t.Fatalf("%s: %v", test.json, err)
+    }
+    if found && string(data) != test.want {
+      t.Errorf("unsetJSONKey(%s, %q) left %s, want %s", test.json, test.key, data, test.want)
+    }
+  }
+}
+
+
+func TestConfigOption(t *testing.T) {
+  p := newTestProgram("tool")
+  p.Options.Bool("verbose", false, "Print more")
+  build := NewCommand("build", "Build", func(opt *struct {
+    Jobs   int    `Parallel jobs`
+    OutDir string `deprecated:"out" Output directory`
+  }) {})
+  build.Aliases = []string{"b"}
+  p.AddCommand(build)
+
+  tests := []struct {
+    key  string
+    want string // canonical key
+    err  string // start of the error message
+  }{
+    {key: "verbose", want: "verbose"},
+    {key: "build.jobs", want: "build.jobs"},
+    {key: "b.jobs", want: "build.jobs"},
+    {key: "profile.ci.b.jobs", want: "profile.ci.build.jobs"},
+    {key: "profile.ci.extends", want: "profile.ci.extends"},
+    {key: "alias.st", want: "alias.st"},
+    {key: "biuld.jobs", err: `biuld.jobs: unknown command "biuld"; did you mean "build"?`},
+    {key: "build.jbos", err: "build.jbos: unknown option -jbos; did you mean -jobs?"},
+    {key: "build.verbose", err: "build.verbose: unknown option -verbose"},
+    {key: "build.out", err: "build.out: option -out is deprecated; use -out-dir instead"},
+  }
+  for _, test := range tests {
+    key, _, _, err := p.configOption(test.key)
+    if test.err != "" {
+      if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+        t.Errorf("%s: error %v, want %q", test.key, err, test.err)
+      }
+    } else if err != nil {
+      t.Errorf("%s: unexpected error: %v", test.key, err)
+    } else if key != test.want {
+      t.Errorf("%s: key %q, want %q", test.key, key, test.want)
+    }
+  }
+}
@@ -0,0 +1,102 @@
+package cmdr
+import (
+  "flag"
+  "fmt"
+  "os"
+  "strings"
+)
+
+
+// Returns the name of the environment variable providing a value for the option `name`, which
+// is either set with an `env:"NAME"` field tag or derived from p.EnvPrefix, e.g. "APP_FIRST_NAME"
+// for -first-name. Returns "" if the option has no environment variable.
+func (p *Program) optionEnv(name string, info *optionInfo) string {
+  if info != nil && info.env != "" {
+    return info.env
+  }
+  if p != nil && p.EnvPrefix != "" {
+    return p.EnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+  }
+  return ""
+}
+
+
+// Returns the name of the environment variable holding options for cmd (Program.DefaultArgsEnv),
+// e.g. "TOOL_REMOTE_ADD_FLAGS" for "remote add" when DefaultArgsEnv is "TOOL_FLAGS"
+func commandArgsEnv(argsEnv string, cmd *Command) string {
+  name := strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_").Replace(cmd.Path()))
+  if i := strings.LastIndexByte(argsEnv, '_'); i != -1 {
+    return argsEnv[:i] + "_" + name + argsEnv[i:]
+  }
+  return argsEnv + "_" + name
+}
+
+
+// Sets the options of cmd given by the environment variables named by Program.DefaultArgsEnv.
+// Returns where each option set this way came from.
+func (cmd *Command) parseEnvArgs() (map[string]ValueSource, error) {
+  p := cmd.Program
+  if p == nil || p.DefaultArgsEnv == "" {
+    return nil, nil
+  }
+  sources := make(map[string]ValueSource)
+  for _, name := range []string{p.DefaultArgsEnv, commandArgsEnv(p.DefaultArgsEnv, cmd)} {
+    value, ok := os.LookupEnv(name)
+    if !ok {
+      continue
+    }
+    args, err := splitWords(value)
+    if err == nil && name == p.DefaultArgsEnv {
+      args, err = cmd.applicableOptions(args)
+    }
+    if err != nil {
+      return nil, fmt.Errorf("$%s: %v", name, err)
+    }
+
+    // Parse into a FlagSet sharing cmd's option values, leaving cmd.Options to arguments
+    fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+    cmd.Options.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+    if err := parseOptions(p, fs, cmd.options, args, false); err != nil {
+      return nil, fmt.Errorf("$%s: %v", name, err)
+    }
+    if fs.NArg() != 0 {
+      return nil, fmt.Errorf("$%s: unexpected argument %q", name, fs.Arg(0))
+    }
+    fs.Visit(func(f *flag.Flag) {
+      if dv, ok := f.Value.(*deprecatedValue); ok {
+        sources[dv.replacement] = ValueSource{Kind: SourceEnv, Name: name}
+      } else {
+        sources[f.Name] = ValueSource{Kind: SourceEnv, Name: name}
+      }
+    })
+  }
+  return sources, nil
+}
+
+
+// Returns the options in args which cmd defines. Each argument must be an option with its value
+// in the same argument, e.g. "-jobs=8".
+func (cmd *Command) applicableOptions(args []string) ([]string, error) {
+  gnu := cmd.Program != nil && cmd.Program.GNUOptions
+  var applicable []string
+  for _, arg := range args {
+    if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+      return nil, fmt.Errorf("%q is not an option", arg)
+    }
+    var defined bool
+    if gnu && arg[1] != '-' {
+      defined = shortOptionName(cmd.options, arg[1:2]) != ""
+    } else {
+      name := strings.TrimLeft(arg, "-")
+      if i := strings.IndexByte(name, '='); i != -1 {
+        name = name[:i]
+      }
+      defined = cmd.Options.Lookup(name) != nil ||
+                (strings.HasPrefix(name, "no-") && cmd.Options.Lookup(name[3:]) != nil)
+    }
+    if defined {
+      applicable = append(applicable, arg)
+    }
+  }
+  return applicable, nil
+}
@@ -0,0 +1,183 @@
+package cmdr
+import (
+  "flag"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "runtime"
+  "sort"
+  "strings"
+)
+
+
+// Returns the prefix of the names of p's external commands, e.g. "tool-"
+func (p *Program) externalPrefix() string {
+  return filepath.Base(p.Name) + "-"
+}
+
+
+// Returns the prefix of the names of p's plugins, e.g. "tool-plugin-"
+func (p *Program) pluginPrefix() string {
+  return p.externalPrefix() + "plugin-"
+}
+
+
+// Looks for the executable of the plugin (PluginCommands) or external command (ExternalCommands)
+// `name` in PATH. Returns nil if there's no such executable. Plugins are returned as commands
+// with the options and arguments they describe.
+func (p *Program) lookupExternal(name string) (*Command, error) {
+  if name == "" || strings.ContainsAny(name, `/\`) {
+    return nil, nil
+  }
+  if p.PluginCommands {
+    if path, err := exec.LookPath(p.pluginPrefix() + name); err == nil {
+      d, err := describePlugin(path)
+      var cmd *Command
+      if err == nil {
+        cmd, err = pluginCommand(p, name, path, d)
+      }
+      if err != nil {
+        return nil, fmt.Errorf("plugin %s: %v", filepath.Base(path), err)
+      }
+      return cmd, nil
+    }
+  }
+  if !p.ExternalCommands {
+    return nil, nil
+  }
+  path, err := exec.LookPath(p.externalPrefix() + name)
+  if err != nil {
+    return nil, nil
+  }
+  return externalCommand(p, name, path), nil
+}
+
+
+// Returns a command which runs the executable at `path` with the command's arguments.
+// The program's options are passed on as environment variables.
+func externalCommand(p *Program, name, path string) *Command {
+  cmd := NewCommand(name, "", nil)
+  cmd.Program = p
+  cmd.rawArgs = true
+  cmd.main = func(cmd *Command) {
+    c := exec.Command(path, cmd.Options.Args()...)
+    c.Env = append(os.Environ(), cmd.Program.optionEnvVars()...)
+    runExternal(cmd, c)
+  }
+  return cmd
+}
+
+
+// Shows help for the external command `name` by running its executable with "--help"
+func (p *Program) externalHelp(cmd *Command, name string) {
+  path, err := exec.LookPath(p.externalPrefix() + name)
+  if err != nil {
+    cmd.Fail(err)
+  }
+  c := exec.Command(path, "--help")
+  c.Env = append(os.Environ(), p.optionEnvVars()...)
+  runExternal(cmd, c)
+}
+
+
+// Returns "NAME=value" environment variables holding the values of p's options, named like
+// EnvPrefix does, e.g. "TOOL_VERBOSE=true". The program's name is used as the prefix when
+// EnvPrefix is empty. When p's options are flag.CommandLine, as DefaultProgram's are by
+// default, flags defined by other packages are left out.
+func (p *Program) optionEnvVars() []string {
+  q := *p
+  if q.EnvPrefix == "" {
+    q.EnvPrefix = strings.ToUpper(strings.Replace(filepath.Base(p.Name), "-", "_", -1)) + "_"
+  }
+  var env []string
+  p.Options.VisitAll(func(f *flag.Flag) {
+    if _, ok := f.Value.(*deprecatedValue); ok {
+      return
+    }
+    if p.Options == flag.CommandLine && p.options[f.Name] == nil {
+      return // not defined by the program (see defineOption)
+    }
+    env = append(env, q.optionEnv(f.Name, p.options[f.Name]) + "=" + f.Value.String())
+  })
+  return env
+}
+
+
+// Returns the names of the plugins and external commands found in PATH, excluding any which are
+// shadowed by the program's own commands
+func (p *Program) externalCommandNames() []string {
+  if !p.ExternalCommands && !p.PluginCommands {
+    return nil
+  }
+  pluginPrefix, externalPrefix := p.pluginPrefix(), p.externalPrefix()
+  seen := make(map[string]bool)
+  var names []string
+  for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+    files, _ := ioutil.ReadDir(dir)
+    for _, fi := range files {
+      name := executableName(fi)
+      if p.PluginCommands && strings.HasPrefix(name, pluginPrefix) {
+        name = name[len(pluginPrefix):]
+      } else if p.ExternalCommands && strings.HasPrefix(name, externalPrefix) {
+        name = name[len(externalPrefix):]
+      } else {
+        continue
+      }
+      if name != "" && !seen[name] && !p.hasCommand(name) {
+        seen[name] = true
+        names = append(names, name)
+      }
+    }
+  }
+  sort.Strings(names)
+  return names
+}
+
+
+// Returns the name of the executable file fi without its extension on Windows, e.g. "tool-x"
+// for "tool-x.exe", or "" if fi is not executable. On Windows, files are executable if their
+// extension is listed in $PATHEXT, like for exec.LookPath.
+func executableName(fi os.FileInfo) string {
+  if fi.IsDir() {
+    return ""
+  }
+  name := fi.Name()
+  if runtime.GOOS != "windows" {
+    if fi.Mode() & 0111 == 0 {
+      return ""
+    }
+    return name
+  }
+  pathext := os.Getenv("PATHEXT")
+  if pathext == "" {
+    pathext = ".com;.exe;.bat;.cmd"
+  }
+  ext := filepath.Ext(name)
+  for _, e := range strings.Split(pathext, ";") {
+    if e != "" && strings.EqualFold(e, ext) {
+      return name[:len(name) - len(ext)]
+    }
+  }
+  return ""
+}
+
+
+// Lists the plugins and external commands found in PATH, if any, with the descriptions of
+// plugins
+func (p *Program) externalCommandsUsage(w io.Writer) {
+  names := p.externalCommandNames()
+  if len(names) == 0 {
+    return
+  }
+  io.WriteString(w, "External commands:\n")
+  for _, name := range names {
+    if cmd, _ := p.lookupExternal(name); cmd != nil && !cmd.rawArgs {
+      cmd.usageLine(w)
+    } else {
+      fmt.Fprintf(w, "  %s\t\n", name)
+    }
+  }
+}
@@ -0,0 +1,225 @@
+package cmdr
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+)
+
+
+// Reads lines from a terminal, with editing, history and completion. Lines are read without
+// editing when the input isn't a terminal.
+type lineEditor struct {
+  fd       int
+  in       *bufio.Reader
+  history  []string
+  complete func(line string) (start int, candidates []string)
+
+  // state of the line being edited
+  prompt   string
+  buf      []rune
+  pos      int // cursor position in buf
+}
+
+
+func newLineEditor(fd int, complete func(string) (int, []string)) *lineEditor {
+  return &lineEditor{fd: fd, in: bufio.NewReader(os.Stdin), complete: complete}
+}
+
+
+// Adds line to the history unless it repeats the last line. Returns true if line was added.
+func (e *lineEditor) addHistory(line string) bool {
+  if len(e.history) != 0 && e.history[len(e.history)-1] == line {
+    return false
+  }
+  e.history = append(e.history, line)
+  return true
+}
+
+
+// Reads a line, showing `prompt` when reading from a terminal. Returns io.EOF at the end of
+// input, or when ctrl-D is pressed on an empty line.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+  restore, err := makeRaw(e.fd)
+  if err != nil {
+    // not a terminal
+    line, err := e.in.ReadString('\n')
+    if err == io.EOF && line != "" {
+      err = nil
+    }
+    return strings.TrimRight(line, "\r\n"), err
+  }
+  defer restore()
+
+  e.prompt, e.buf, e.pos = prompt, nil, 0
+  historyIndex := len(e.history)
+  var draft []rune // line being edited before moving through the history
+  e.refresh()
+  for {
+    r, _, err := e.in.ReadRune()
+    if err != nil {
+      os.Stdout.WriteString("\r\n")
+      return "", err
+    }
+    switch r {
+    case '\r', '\n':
+      os.Stdout.WriteString("\r\n")
+      return string(e.buf), nil
+    case 3: // ctrl-C
+      os.Stdout.WriteString("^C\r\n")
+      e.buf, e.pos, historyIndex = nil, 0, len(e.history)
+    case 4: // ctrl-D
+      if len(e.buf) == 0 {
+        os.Stdout.WriteString("\r\n")
+        return "", io.EOF
+      }
+      e.delete(e.pos)
+    case 127, 8: // backspace
+      if e.pos > 0 {
+        e.pos--
+        e.delete(e.pos)
+      }
+    case 1: // ctrl-A
+      e.pos = 0
+    case 5: // ctrl-E
+      e.pos = len(e.buf)
+    case 2: // ctrl-B
+      e.move(-1)
+    case 6: // ctrl-F
+      e.move(1)
+    case 11: // ctrl-K
+      e.buf = e.buf[:e.pos]
+    case 21: // ctrl-U
+      e.buf = append([]rune{}, e.buf[e.pos:]...)
+      e.pos = 0
+    case 16, 14: // ctrl-P, ctrl-N
+      historyIndex, draft = e.browseHistory(historyIndex, draft, r == 16)
+    case '\t':
+      e.completeWord()
+    case 27: // escape sequence
+      seq := e.readEscape()
+      switch seq {
+      case "[A", "OA":
+        historyIndex, draft = e.browseHistory(historyIndex, draft, true)
+      case "[B", "OB":
+        historyIndex, draft = e.browseHistory(historyIndex, draft, false)
+      case "[C", "OC":
+        e.move(1)
+      case "[D", "OD":
+        e.move(-1)
+      case "[H", "OH", "[1~":
+        e.pos = 0
+      case "[F", "OF", "[4~":
+        e.pos = len(e.buf)
+      case "[3~":
+        e.delete(e.pos)
+      }
+    default:
+      if r >= ' ' {
+        e.insert(string(r))
+      }
+    }
+    e.refresh()
+  }
+}
+
+
+// Reads the rest of an escape sequence, e.g. "[A" for the up arrow key
+func (e *lineEditor) readEscape() string {
+  var seq []byte
+  for len(seq) < 8 {
+    b, err := e.in.ReadByte()
+    if err != nil {
+      break
+    }
+    seq = append(seq, b)
+    // sequences end with a letter or "~", except for their first byte
+    if len(seq) > 1 && (b == '~' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')) {
+      break
+    }
+  }
+  return string(seq)
+}
+
+
+// Redraws the line and places the cursor
+func (e *lineEditor) refresh() {
+  fmt.Fprintf(os.Stdout, "\r%s%s\x1b[K", e.prompt, string(e.buf))
+  if n := len(e.buf) - e.pos; n > 0 {
+    fmt.Fprintf(os.Stdout, "\x1b[%dD", n)
+  }
+}
+
+
+func (e *lineEditor) insert(s string) {
+  r := []rune(s)
+  e.buf = append(e.buf[:e.pos], append(r, e.buf[e.pos:]...)...)
+  e.pos += len(r)
+}
+
+
+func (e *lineEditor) delete(i int) {
+  if i < len(e.buf) {
+    e.buf = append(e.buf[:i], e.buf[i+1:]...)
+  }
+}
+
+
+func (e *lineEditor) move(n int) {
+  if pos := e.pos + n; pos >= 0 && pos <= len(e.buf) {
+    e.pos = pos
+  }
+}
+
+
+// Replaces the line with the previous (`back`) or next line of the history. Returns the new
+// position in the history and the line which was being edited before browsing the history.
+func (e *lineEditor) browseHistory(index int, draft []rune, back bool) (int, []rune) {
+  if back && index > 0 {
+    if index == len(e.history) {
+      draft = e.buf
+    }
+    index--
+    e.buf = []rune(e.history[index])
+  } else if !back && index < len(e.history) {
+    index++
+    if index == len(e.history) {
+      e.buf = draft
+    } else {
+      e.buf = []rune(e.history[index])
+    }
+  }
+  e.pos = len(e.buf)
+  return index, draft
+}
+
+
+// Completes the word before the cursor. The word is completed as far as the candidates agree,
+// and the candidates are listed when that doesn't change the word.
+func (e *lineEditor) completeWord() {
+  if e.complete == nil {
+    return
+  }
+  line := string(e.buf[:e.pos])
+  start, candidates := e.complete(line)
+  word := line[start:]
+  switch len(candidates) {
+  case 0:
+    os.Stdout.WriteString("\a")
+  case 1:
+    e.insert(candidates[0][len(word):] + " ")
+  default:
+    prefix := candidates[0]
+    for _, c := range candidates[1:] {
+      for !strings.HasPrefix(c, prefix) {
+        prefix = prefix[:len(prefix)-1]
+      }
+    }
+    if len(prefix) > len(word) {
+      e.insert(prefix[len(word):])
+    } else {
+      os.Stdout.WriteString("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+    }
+  }
+}
@@ -0,0 +1,99 @@
+package cmdr
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+
+// Returns the command named by the base name of os.Args[0] when p has Multicall set,
+// e.g. "ls" when the program is run through a link named "ls". When p is named after the link,
+// as DefaultProgram is, it's renamed after its executable, so that messages name the program
+// rather than the command.
+func (p *Program) multicallCommand() string {
+  if !p.Multicall {
+    return ""
+  }
+  name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+  if name == "help" || !p.hasCommand(name) {
+    return ""
+  }
+  exe, err := os.Executable()
+  if err == nil {
+    exe, err = filepath.EvalSymlinks(exe)
+  }
+  if err != nil {
+    return name
+  }
+  exeName := strings.TrimSuffix(filepath.Base(exe), ".exe")
+  if exeName == name {
+    return "" // not run through a link
+  }
+  if strings.TrimSuffix(filepath.Base(p.Name), ".exe") == name {
+    p.Name = exeName
+  }
+  return name
+}
+
+
+// A command that links to the program's executable under the name of each of its commands
+// (Program.Multicall)
+var InstallLinksCommand = NewCommand("install-links", "Create links for running commands by name",
+func (opt *struct {
+  Force bool   `Replace existing links`
+  Dir   string `! Directory to create links in`
+}, cmd *Command) {
+  exe, err := os.Executable()
+  if err == nil {
+    exe, err = filepath.EvalSymlinks(exe)
+  }
+  if err != nil {
+    cmd.Fail(err)
+  }
+  failed := false
+  for _, name := range commandNames(cmd.Program.Commands) {
+    if c := cmd.Program.Commands[name]; c.Hidden || c == cmd {
+      continue
+    }
+    link := filepath.Join(opt.Dir, name)
+    if target, err := os.Readlink(link); err == nil && target == exe {
+      continue // already installed
+    }
+    if opt.Force {
+      if err := removeLink(link, exe); err != nil {
+        cmd.warnf("%v", err)
+        failed = true
+        continue
+      }
+    }
+    if err := os.Symlink(exe, link); err != nil {
+      cmd.warnf("%v", err)
+      failed = true
+      continue
+    }
+    cmd.Logf("%s -> %s", link, exe)
+  }
+  if failed {
+    cmd.Fail("some links could not be created")
+  }
+})
+
+
+// Removes `link` if it's a symbolic link or a hard link to the executable `exe`. Other files are
+// left alone, returning an error. Does nothing if there's no such file.
+func removeLink(link, exe string) error {
+  fi, err := os.Lstat(link)
+  if os.IsNotExist(err) {
+    return nil
+  } else if err != nil {
+    return err
+  }
+  if fi.Mode() & os.ModeSymlink == 0 {
+    exeInfo, err := os.Stat(exe)
+    if err != nil || !os.SameFile(fi, exeInfo) {
+      return fmt.Errorf("%s: not replacing a file which is not a link", link)
+    }
+  }
+  return os.Remove(link)
+}
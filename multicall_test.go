@@ -0,0 +1,75 @@
+package cmdr
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+
+func TestMulticall(t *testing.T) {
+  exe, err := os.Executable()
+  if err == nil {
+    exe, err = filepath.EvalSymlinks(exe)
+  }
+  if err != nil {
+    t.Skip(err)
+  }
+  exeName := strings.TrimSuffix(filepath.Base(exe), ".exe")
+  arg0 := os.Args[0]
+  defaultProgram := *DefaultProgram
+  defer func() {
+    os.Args[0] = arg0
+    *DefaultProgram = defaultProgram
+  }()
+
+  var ran []string
+  addCommands := func(p *Program) {
+    p.AddCommand(NewCommand("hello", "Greet", func(opt *struct {
+      Name string `! Name`
+    }, cmd *Command) {
+      ran = append(ran, cmd.Program.Name + ": hello " + opt.Name)
+    }))
+    p.AddCommand(NewCommand("echo", "Echo", func(opt *struct {
+      N    int      `="1" Times`
+      Args []string `? Words`
+    }, cmd *Command) {
+      ran = append(ran, cmd.Program.Name + ": echo " + strings.Join(opt.Args, " "))
+    }))
+  }
+  check := func(desc string, want string) {
+    if len(ran) != 1 || ran[0] != want {
+      t.Errorf("%s: ran %q, want %q", desc, ran, want)
+    }
+    ran = nil
+  }
+
+  p := newTestProgram("tool")
+  p.Multicall = true
+  addCommands(p)
+  os.Args[0] = "/tmp/links/hello"
+  p.Main([]string{"bob"})
+  check("tool via hello", "tool: hello bob")
+  os.Args[0] = arg0
+  p.Main([]string{"hello", "bob"})
+  check("tool", "tool: hello bob")
+  p.Multicall = false
+  os.Args[0] = "/tmp/links/hello"
+  p.Main([]string{"hello", "bob"})
+  check("tool via hello without Multicall", "tool: hello bob")
+
+  // DefaultProgram is named after os.Args[0]
+  *DefaultProgram = *newTestProgram("/tmp/links/hello")
+  DefaultProgram.Multicall = true
+  addCommands(DefaultProgram)
+  DefaultProgram.Main([]string{"bob"})
+  check("DefaultProgram via hello", exeName + ": hello bob")
+
+  *DefaultProgram = *newTestProgram("")
+  DefaultProgram.Multicall = true
+  os.Args[0] = "/tmp/links/echo"
+  UsePrivateFlagSet()
+  addCommands(DefaultProgram)
+  DefaultProgram.Main([]string{"-n", "5", "hi"})
+  check("DefaultProgram with UsePrivateFlagSet via echo", exeName + ": echo hi")
+}
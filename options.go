@@ -0,0 +1,266 @@
+package cmdr
+import (
+  "flag"
+  "fmt"
+  "reflect"
+  "strings"
+  "unicode/utf8"
+)
+
+
+// Parses the options at the beginning of args into fs. Parsing stops at the first non-option
+// argument or after "--". When `interspersed` is true, options may also follow non-option
+// arguments and parsing only stops at "--". The remaining non-option arguments are available
+// from fs.Args() afterwards. `options` holds additional information on the options of fs and
+// may be nil.
+//
+// Options use the syntax of the standard "flag" package, unless p has GNUOptions set.
+// Unlike flag.FlagSet.Parse, nothing is printed and the error handling mode of fs is ignored.
+// flag.ErrHelp is returned when -h or -help is given but not defined. errShowConfig is returned
+// after parsing all options when -show-config is given and p has ShowConfigOption set.
+func parseOptions(
+  p *Program, fs *flag.FlagSet, options map[string]*optionInfo, args []string, interspersed bool,
+) error {
+  gnu := p != nil && p.GNUOptions
+  showConfig := false
+  var positional []string
+  i := 0
+  for i < len(args) {
+    arg := args[i]
+    if len(arg) < 2 || arg[0] != '-' {
+      if !interspersed {
+        break
+      }
+      positional = append(positional, arg)
+      i++
+      continue
+    }
+    i++
+    if arg == "--" {
+      break
+    }
+
+    if gnu && arg[1] != '-' {
+      // One or more short options, e.g. "-v", "-ofile" or "-abc"
+      var err error
+      i, err = parseShortOptions(fs, options, arg[1:], args, i)
+      if err != nil {
+        return err
+      }
+      continue
+    }
+
+    name := arg[1:]
+    if name[0] == '-' {
+      name = name[1:]
+    }
+    if len(name) == 0 || name[0] == '-' || name[0] == '=' {
+      return fmt.Errorf("bad option syntax: %s", arg)
+    }
+
+    value, hasValue := "", false
+    if j := strings.IndexByte(name, '='); j != -1 {
+      name, value, hasValue = name[:j], name[j+1:], true
+    }
+
+    f := fs.Lookup(name)
+    if f == nil && strings.HasPrefix(name, "no-") {
+      // Negated boolean option, e.g. -no-color for -color=false
+      if f = fs.Lookup(name[3:]); f != nil && isNegatable(f, options[f.Name]) {
+        if hasValue {
+          return fmt.Errorf("option %s doesn't take a value", p.dash(name))
+        }
+        name, value, hasValue = f.Name, "false", true
+      } else {
+        f = nil
+      }
+    }
+    if f == nil {
+      if name == "help" || (name == "h" && !gnu) {
+        return flag.ErrHelp
+      }
+      if name == "show-config" && !hasValue && p.showConfigOption(fs) {
+        showConfig = true
+        continue
+      }
+      return unknownOptionError(p, fs, options, name)
+    }
+
+    if implicit, ok := implicitValue(f.Value); ok {
+      if !hasValue {
+        value = implicit
+      }
+    } else if isBoolFlag(f.Value) {
+      if !hasValue {
+        value = "true"
+      }
+    } else if !hasValue {
+      if i == len(args) {
+        return fmt.Errorf("option %s needs a value", p.dash(name))
+      }
+      value = args[i]
+      i++
+    }
+
+    if err := fs.Set(name, value); err != nil {
+      return fmt.Errorf("invalid value %q for option %s: %v", value, p.dash(name), err)
+    }
+  }
+
+  // Mark fs as parsed, leaving the remaining arguments in fs.Args()
+  positional = append(positional, args[i:]...)
+  fs.Parse(append([]string{"--"}, positional...))
+  if showConfig {
+    return errShowConfig
+  }
+  return nil
+}
+
+
+// Parses a cluster of short options `chars`, as found in args[i-1]. Boolean options can be
+// bundled, e.g. "-abc", and the last option may take a value, either from the rest of chars
+// ("-ofile") or from the next argument ("-o file"). Options with optional values only take a
+// value from the rest of chars. Returns the index of the next argument.
+func parseShortOptions(
+  fs *flag.FlagSet, options map[string]*optionInfo, chars string, args []string, i int,
+) (int, error) {
+  for j := 0; j < len(chars); {
+    _, size := utf8.DecodeRuneInString(chars[j:])
+    short := chars[j:j+size]
+    j += size
+    name := shortOptionName(options, short)
+    if name == "" {
+      if short == "h" {
+        return i, flag.ErrHelp
+      }
+      return i, fmt.Errorf("unknown option -%s", short)
+    }
+    f := fs.Lookup(name)
+    value := "true"
+    if implicit, ok := implicitValue(f.Value); ok {
+      // value is optional and can only be given as part of chars, e.g. "-cnever"
+      value = implicit
+      if j < len(chars) {
+        value = chars[j:]
+      }
+      j = len(chars)
+    } else if !isBoolFlag(f.Value) {
+      if j < len(chars) {
+        value = chars[j:]
+      } else if i < len(args) {
+        value = args[i]
+        i++
+      } else {
+        return i, fmt.Errorf("option -%s needs a value", short)
+      }
+      j = len(chars)
+    }
+    if err := fs.Set(name, value); err != nil {
+      return i, fmt.Errorf("invalid value %q for option -%s: %v", value, short, err)
+    }
+  }
+  return i, nil
+}
+
+
+// Returns the name of the option with the short name `short`, or "" if there's no such option
+func shortOptionName(options map[string]*optionInfo, short string) string {
+  for name, info := range options {
+    if info.short == short {
+      return name
+    }
+  }
+  return ""
+}
+
+
+// Returns the option `name` prefixed with dashes according to the option syntax of p,
+// e.g. "-name" or "--name"
+func (p *Program) dash(name string) string {
+  if p != nil && p.GNUOptions {
+    return "--" + name
+  }
+  return "-" + name
+}
+
+
+func isBoolFlag(v flag.Value) bool {
+  bf, ok := v.(interface { IsBoolFlag() bool })
+  return ok && bf.IsBoolFlag()
+}
+
+
+// Returns the value an option is set to when given without a value, if its value is optional
+func implicitValue(v flag.Value) (string, bool) {
+  if dv, ok := v.(*deprecatedValue); ok {
+    v = dv.ValueBinding
+  }
+  if ov, ok := v.(OptionalValueBinding); ok {
+    return ov.ImplicitValue(), true
+  }
+  return "", false
+}
+
+
+// True if the boolean option f can be negated with a "no-" prefix
+func isNegatable(f *flag.Flag, info *optionInfo) bool {
+  return isBoolFlag(f.Value) && (info == nil || !info.noNegate)
+}
+
+
+func unknownOptionError(
+  p *Program, fs *flag.FlagSet, options map[string]*optionInfo, name string,
+) error {
+  var names []string
+  fs.VisitAll(func(f *flag.Flag) {
+    if !options[f.Name].isHidden() {
+      names = append(names, f.Name)
+    }
+  })
+  if hint := didYouMean(suggest(name, names), p.dash("%s")); hint != "" {
+    return fmt.Errorf("unknown option %s; %s", p.dash(name), hint)
+  }
+  return fmt.Errorf("unknown option %s", p.dash(name))
+}
+
+
+// Appends a "See '...'" hint to msg
+func seeHelp(msg, see string) string {
+  if !strings.HasSuffix(msg, "?") {
+    msg += "."
+  }
+  return msg + " See '" + see + "'"
+}
+
+
+// Information about an option, in addition to what's held by flag.Flag
+type optionInfo struct {
+  hidden  bool   // not listed in usage
+  short   string // single-character name, used with GNUOptions
+  argName string // name of the option's value in usage, e.g. "FILE"
+  noNegate bool  // boolean option can't be negated with "no-" prefix
+  inherited bool // program option added to a command (Program.PersistentOptions)
+  env     string // name of environment variable providing a value
+  typ     reflect.Type // type of the struct field the option is bound to, if any
+}
+
+
+func (info *optionInfo) isHidden() bool {
+  return info != nil && info.hidden
+}
+
+
+// Binding for a deprecated name of an option, which prints a warning when set
+type deprecatedValue struct {
+  ValueBinding
+  cmd         *Command
+  name        string
+  replacement string
+}
+
+func (v *deprecatedValue) IsBoolFlag() bool { return isBoolFlag(v.ValueBinding) }
+func (v *deprecatedValue) Set(s string) error {
+  p := v.cmd.Program
+  v.cmd.warnf("option %s is deprecated; use %s instead", p.dash(v.name), p.dash(v.replacement))
+  return v.ValueBinding.Set(s)
+}
@@ -0,0 +1,127 @@
+package cmdr
+import (
+  "flag"
+  "fmt"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+
+type parseTestOptions struct {
+  Verbose bool     `short:"v" Print more`
+  All     bool     `short:"a" Include everything`
+  Output  string   `short:"o" Output file`
+  Jobs    int      `short:"j" ="1" Parallel jobs`
+  Color   bool     `negatable:"false" ="true" Colorize`
+  Cache   bool     `="true" Use the cache`
+  Mode    TriState `short:"m" Mode`
+  OutDir  string   `deprecated:"out" Output directory`
+}
+
+func (o *parseTestOptions) String() string {
+  return fmt.Sprintf("v=%v a=%v o=%q j=%d color=%v cache=%v m=%v dir=%q",
+                     o.Verbose, o.All, o.Output, o.Jobs, o.Color, o.Cache, o.Mode, o.OutDir)
+}
+
+
+func TestParseOptions(t *testing.T) {
+  defaults := (&parseTestOptions{Jobs: 1, Color: true, Cache: true}).String()
+  tests := []struct {
+    gnu          bool
+    interspersed bool
+    showConfig   bool
+    args         []string
+    want         string // options, or "" for defaults
+    rest         []string
+    err          string // start of the error message
+  }{
+    // "flag" package syntax
+    {args: []string{}},
+    {args: []string{"-verbose", "-output", "x", "-jobs=4"},
+     want: `v=true a=false o="x" j=4 color=true cache=true m=auto dir=""`},
+    {args: []string{"--verbose", "--output=x"},
+     want: `v=true a=false o="x" j=1 color=true cache=true m=auto dir=""`},
+    {args: []string{"-verbose=false", "-cache=false"},
+     want: `v=false a=false o="" j=1 color=true cache=false m=auto dir=""`},
+    {args: []string{"-no-cache", "file"},
+     want: `v=false a=false o="" j=1 color=true cache=false m=auto dir=""`,
+     rest: []string{"file"}},
+    {args: []string{"-mode", "x"},
+     want: `v=false a=false o="" j=1 color=true cache=true m=true dir=""`, rest: []string{"x"}},
+    {args: []string{"-mode=never"},
+     want: `v=false a=false o="" j=1 color=true cache=true m=false dir=""`},
+    {args: []string{"-out", "d"},
+     want: `v=false a=false o="" j=1 color=true cache=true m=auto dir="d"`},
+    {args: []string{"a", "-verbose"}, rest: []string{"a", "-verbose"}},
+    {args: []string{"-", "-verbose"}, rest: []string{"-", "-verbose"}},
+    {args: []string{"--", "-verbose"}, rest: []string{"-verbose"}},
+    {args: []string{"-no-color"}, err: "unknown option -no-color"},
+    {args: []string{"-no-cache=true"}, err: "option -no-cache doesn't take a value"},
+    {args: []string{"-output"}, err: "option -output needs a value"},
+    {args: []string{"-jobs=x"}, err: `invalid value "x" for option -jobs: invalid number`},
+    {args: []string{"-jbos=2"}, err: "unknown option -jbos; did you mean -jobs?"},
+    {args: []string{"---verbose"}, err: "bad option syntax: ---verbose"},
+    {args: []string{"-=x"}, err: "bad option syntax: -=x"},
+    {args: []string{"-h"}, err: flag.ErrHelp.Error()},
+    {args: []string{"-help"}, err: flag.ErrHelp.Error()},
+    {args: []string{"-show-config"}, err: "unknown option -show-config"},
+    {showConfig: true, args: []string{"-show-config", "-verbose"}, err: errShowConfig.Error()},
+    {showConfig: true, args: []string{"-show-config=1"}, err: "unknown option -show-config"},
+
+    // interspersed options
+    {interspersed: true, args: []string{"a", "-verbose", "b"},
+     want: `v=true a=false o="" j=1 color=true cache=true m=auto dir=""`,
+     rest: []string{"a", "b"}},
+    {interspersed: true, args: []string{"a", "--", "-verbose"}, rest: []string{"a", "-verbose"}},
+
+    // GNU syntax
+    {gnu: true, args: []string{"-va", "-ofile", "-j", "8"},
+     want: `v=true a=true o="file" j=8 color=true cache=true m=auto dir=""`},
+    {gnu: true, args: []string{"-avo", "file", "x"},
+     want: `v=true a=true o="file" j=1 color=true cache=true m=auto dir=""`, rest: []string{"x"}},
+    {gnu: true, args: []string{"--output", "file", "--jobs=3", "--no-cache"},
+     want: `v=false a=false o="file" j=3 color=true cache=false m=auto dir=""`},
+    {gnu: true, args: []string{"-m", "x"},
+     want: `v=false a=false o="" j=1 color=true cache=true m=true dir=""`, rest: []string{"x"}},
+    {gnu: true, args: []string{"-mnever"},
+     want: `v=false a=false o="" j=1 color=true cache=true m=false dir=""`},
+    {gnu: true, args: []string{"-vx"}, err: "unknown option -x"},
+    {gnu: true, args: []string{"-o"}, err: "option -o needs a value"},
+    {gnu: true, args: []string{"-jx"}, err: `invalid value "x" for option -j: invalid number`},
+    {gnu: true, args: []string{"-h"}, err: flag.ErrHelp.Error()},
+    {gnu: true, args: []string{"--help"}, err: flag.ErrHelp.Error()},
+  }
+
+  for _, test := range tests {
+    p := &Program{GNUOptions: test.gnu, ShowConfigOption: test.showConfig}
+    var opt parseTestOptions
+    cmd := &Command{Options: flag.NewFlagSet("test", flag.ContinueOnError), Program: p}
+    cmd.bindStruct(reflect.ValueOf(&opt))
+
+    err := parseOptions(p, cmd.Options, cmd.options, test.args, test.interspersed)
+    desc := fmt.Sprintf("gnu=%v interspersed=%v %q", test.gnu, test.interspersed, test.args)
+    if test.err != "" {
+      if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+        t.Errorf("%s: error %v, want %q", desc, err, test.err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("%s: unexpected error: %v", desc, err)
+      continue
+    }
+    want := test.want
+    if want == "" {
+      want = defaults
+    }
+    if got := opt.String(); got != want {
+      t.Errorf("%s:\n  got  %s\n  want %s", desc, got, want)
+    }
+    if rest := cmd.Options.Args(); len(rest) != 0 || len(test.rest) != 0 {
+      if !reflect.DeepEqual(rest, test.rest) {
+        t.Errorf("%s: remaining args %q, want %q", desc, rest, test.rest)
+      }
+    }
+  }
+}
@@ -0,0 +1,191 @@
+package cmdr
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "os"
+  "os/exec"
+  "reflect"
+  "time"
+  "unicode/utf8"
+)
+
+
+// Arguments passed to plugins (Program.PluginCommands)
+const (
+  PluginDescribeArg = "--cmdr-describe" // asks a plugin to print its PluginDescription as JSON
+  PluginRunArg      = "--cmdr-run"      // runs a plugin with PluginInput as JSON on stdin
+)
+
+// How long a plugin may take to describe itself
+const pluginDescribeTimeout = 5 * time.Second
+
+
+// Describes the options and arguments of a plugin command. Printed as JSON by a plugin when
+// run with PluginDescribeArg.
+type PluginDescription struct {
+  Description string         `json:"description,omitempty"`
+  Options     []PluginOption `json:"options,omitempty"`
+  Args        []PluginArg    `json:"args,omitempty"`
+}
+
+type PluginOption struct {
+  Name        string `json:"name"`
+  Type        string `json:"type,omitempty"` // see PluginArg.Type
+  Default     string `json:"default,omitempty"`
+  Description string `json:"description,omitempty"`
+  Short       string `json:"short,omitempty"` // single-character name, used with GNUOptions
+  Hidden      bool   `json:"hidden,omitempty"`
+}
+
+type PluginArg struct {
+  Name        string `json:"name"`
+  // "string" (the default), "bool", "int", "uint", "float", "duration" or "tristate"
+  Type        string `json:"type,omitempty"`
+  Description string `json:"description,omitempty"`
+  Optional    bool   `json:"optional,omitempty"`
+  Variadic    bool   `json:"variadic,omitempty"` // takes all remaining arguments; must be last
+}
+
+// The parsed options and arguments of a plugin command, written as JSON to the plugin's stdin
+// when run with PluginRunArg. Durations are given as strings like "1m30s".
+type PluginInput struct {
+  Command string                 `json:"command"`
+  Options map[string]interface{} `json:"options"`
+  Args    map[string]interface{} `json:"args"`
+}
+
+
+// Asks the plugin at `path` to describe itself
+func describePlugin(path string) (*PluginDescription, error) {
+  ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+  defer cancel()
+  out, err := exec.CommandContext(ctx, path, PluginDescribeArg).Output()
+  if err != nil {
+    return nil, fmt.Errorf("failed to describe itself: %v", err)
+  }
+  var d PluginDescription
+  if err := json.Unmarshal(out, &d); err != nil {
+    return nil, fmt.Errorf("invalid description: %v", err)
+  }
+  return &d, nil
+}
+
+
+// Returns a command with the options and arguments of `d`, which runs the plugin at `path`
+func pluginCommand(p *Program, name, path string, d *PluginDescription) (*Command, error) {
+  cmd := NewCommand(name, d.Description, nil)
+  cmd.Program = p
+  options := make(map[string]reflect.Value)
+  args := make(map[string]reflect.Value)
+
+  for _, o := range d.Options {
+    T, err := pluginValueType(o.Type)
+    if err != nil {
+      return nil, fmt.Errorf("option %q: %v", o.Name, err)
+    }
+    if o.Name == "" || cmd.Options.Lookup(o.Name) != nil {
+      return nil, fmt.Errorf("missing or duplicate option name %q", o.Name)
+    }
+    if o.Short != "" && utf8.RuneCountInString(o.Short) != 1 {
+      return nil, fmt.Errorf("option %q: short name must be a single character", o.Name)
+    }
+    v := reflect.New(T).Elem()
+    keys := map[string]string{"short": o.Short}
+    if o.Hidden {
+      keys["hidden"] = "true"
+    }
+    cmd.addOption(&v, o.Name, o.Default, o.Description, keys)
+    options[o.Name] = v
+  }
+
+  for i, a := range d.Args {
+    T, err := pluginValueType(a.Type)
+    if err != nil {
+      return nil, fmt.Errorf("argument %q: %v", a.Name, err)
+    }
+    if a.Variadic {
+      if i != len(d.Args) - 1 {
+        return nil, fmt.Errorf("argument %q: only the last argument can be variadic", a.Name)
+      }
+      T = reflect.SliceOf(T)
+    }
+    v := reflect.New(T).Elem()
+    cmd.addArg(&v, a.Optional, a.Name, "", a.Description)
+    args[a.Name] = v
+  }
+  cmd.countOptions()
+
+  cmd.main = func(cmd *Command) {
+    if err := checkPluginArgs(cmd, cmd.Options.Args()); err != nil {
+      cmd.parseError(err)
+      return
+    }
+    input := PluginInput{name, pluginJSONValues(options), pluginJSONValues(args)}
+    data, err := json.Marshal(input)
+    if err != nil {
+      cmd.Fail(err)
+    }
+    c := exec.Command(path, PluginRunArg)
+    c.Env = append(os.Environ(), cmd.Program.optionEnvVars()...)
+    c.Stdin = bytes.NewReader(data)
+    runExternal(cmd, c)
+  }
+  return cmd, nil
+}
+
+
+// Returns an error if `args` lack a required argument of the plugin command cmd, or if there
+// are more of them than cmd takes
+func checkPluginArgs(cmd *Command, args []string) error {
+  for i, arg := range cmd.Args {
+    if i >= len(args) && !arg.Optional {
+      return fmt.Errorf("missing argument <%s>", arg.Name)
+    }
+  }
+  if cmd.VarArgs == nil {
+    if len(args) > len(cmd.Args) {
+      return fmt.Errorf("too many arguments")
+    }
+  } else if len(args) <= len(cmd.Args) && !cmd.VarArgs.Optional {
+    return fmt.Errorf("missing argument <%s>", cmd.VarArgs.Name)
+  }
+  return nil
+}
+
+
+func pluginValueType(name string) (reflect.Type, error) {
+  switch name {
+  case "", "string": return reflect.TypeOf(""), nil
+  case "bool":       return reflect.TypeOf(false), nil
+  case "int":        return reflect.TypeOf(int64(0)), nil
+  case "uint":       return reflect.TypeOf(uint64(0)), nil
+  case "float":      return reflect.TypeOf(float64(0)), nil
+  case "duration":   return durationType, nil
+  case "tristate":   return reflect.TypeOf(TriAuto), nil
+  }
+  return nil, fmt.Errorf("unknown type %q", name)
+}
+
+
+func pluginJSONValues(values map[string]reflect.Value) map[string]interface{} {
+  m := make(map[string]interface{}, len(values))
+  for name, v := range values {
+    switch {
+    case v.Type() == durationType:
+      m[name] = time.Duration(v.Int()).String()
+    case v.Kind() == reflect.Slice && v.Type().Elem() == durationType:
+      s := make([]string, v.Len())
+      for i := range s {
+        s[i] = time.Duration(v.Index(i).Int()).String()
+      }
+      m[name] = s
+    case v.Type() == reflect.TypeOf(TriAuto):
+      m[name] = v.Interface().(TriState).String()
+    default:
+      m[name] = v.Interface()
+    }
+  }
+  return m
+}
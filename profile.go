@@ -0,0 +1,169 @@
+package cmdr
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "sort"
+  "strings"
+)
+
+
+// A named set of option values, applied with -profile NAME. Values given as arguments take
+// precedence over a profile's values, which in turn take precedence over environment variables
+// and config files.
+type Profile struct {
+  Name        string
+  Description string            // shown in usage
+  Extends     []string          // names of profiles whose values this profile builds on
+  Values      map[string]string // option values keyed like config values, e.g. "build.jobs"
+}
+
+
+// Adds a profile and defines the -profile program option, unless the program already has an
+// option with that name. Profiles can also be defined in config files (ConfigName), with keys
+// like "profile.staging.build.jobs", "profile.staging.extends" and "profile.staging.description".
+func (p *Program) AddProfile(prof Profile) {
+  p.Profiles = append(p.Profiles, prof)
+  p.defineProfileOption()
+}
+
+
+func (p *Program) defineProfileOption() {
+  if p.Options == nil {
+    p.Options = flag.NewFlagSet(p.Name, flag.ContinueOnError)
+  }
+  if p.Options.Lookup("profile") == nil {
+    p.Options.StringVar(&p.profile, "profile", "", "Apply a named set of option values")
+  }
+}
+
+
+// Returns the program's profiles by name, including those defined in config files, along with
+// their names in the order they should be listed
+func (p *Program) allProfiles() (map[string]*Profile, []string) {
+  profiles := make(map[string]*Profile)
+  var names []string
+  for _, prof := range p.Profiles {
+    if profiles[prof.Name] == nil {
+      names = append(names, prof.Name)
+    }
+    // copy, so that config values don't modify the profiles of p
+    values := make(map[string]string, len(prof.Values))
+    for key, value := range prof.Values {
+      values[key] = value
+    }
+    prof.Values = values
+    profiles[prof.Name] = &prof
+  }
+
+  c, _ := p.loadConfig()
+  if c == nil {
+    return profiles, names
+  }
+  var configNames []string
+  for key, entry := range c.values {
+    if !strings.HasPrefix(key, "profile.") {
+      continue
+    }
+    key = key[len("profile."):]
+    i := strings.IndexByte(key, '.')
+    if i < 1 {
+      continue
+    }
+    name, key := key[:i], key[i+1:]
+    prof := profiles[name]
+    if prof == nil {
+      prof = &Profile{Name: name, Values: make(map[string]string)}
+      profiles[name] = prof
+      configNames = append(configNames, name)
+    }
+    switch key {
+    case "extends":
+      prof.Extends = strings.Split(entry.value, ",")
+    case "description":
+      prof.Description = entry.value
+    default:
+      prof.Values[key] = entry.value
+    }
+  }
+  sort.Strings(configNames)
+  return profiles, append(names, configNames...)
+}
+
+
+// A value from a profile, as applied by setUnsetOptions
+type profileValue struct {
+  value   string
+  profile string // name of the profile defining the value
+}
+
+
+// Returns the values of the profile selected with -profile, including those of the profiles it
+// extends. Returns nil if no profile is selected.
+func (p *Program) profileValues() (map[string]profileValue, error) {
+  if p == nil || p.profile == "" {
+    return nil, nil
+  }
+  profiles, names := p.allProfiles()
+  values := make(map[string]profileValue)
+  err := resolveProfile(p.profile, profiles, names, values, nil)
+  return values, err
+}
+
+
+// Adds the values of the profile `name` to values, after those of the profiles it extends.
+// `path` holds the names of the profiles extending this one, for detecting cycles.
+func resolveProfile(
+  name string, profiles map[string]*Profile, names []string, values map[string]profileValue,
+  path []string,
+) error {
+  for i, n := range path {
+    if n == name {
+      cycle := strings.Join(append(path[i:], name), " -> ")
+      return fmt.Errorf("profile %q extends itself (%s)", name, cycle)
+    }
+  }
+  prof := profiles[name]
+  if prof == nil {
+    msg := fmt.Sprintf("unknown profile \"%s\"", name)
+    if hint := didYouMean(suggest(name, names), "%q"); hint != "" {
+      msg += "; " + hint
+    }
+    if len(path) != 0 {
+      msg = fmt.Sprintf("profile %q extends %s", path[len(path)-1], msg)
+    }
+    return errors.New(msg)
+  }
+  path = append(path, name)
+  for _, base := range prof.Extends {
+    if err := resolveProfile(strings.TrimSpace(base), profiles, names, values, path); err != nil {
+      return err
+    }
+  }
+  for key, value := range prof.Values {
+    values[key] = profileValue{value, name}
+  }
+  return nil
+}
+
+
+// Lists the program's profiles, if any
+func (p *Program) profilesUsage(w io.Writer) {
+  profiles, names := p.allProfiles()
+  if len(names) == 0 {
+    return
+  }
+  io.WriteString(w, "Profiles:\n")
+  for _, name := range names {
+    prof := profiles[name]
+    fmt.Fprintf(w, "  %s\t%s", name, prof.Description)
+    if len(prof.Extends) != 0 {
+      if prof.Description != "" {
+        io.WriteString(w, " ")
+      }
+      fmt.Fprintf(w, "(extends %s)", strings.Join(prof.Extends, ", "))
+    }
+    io.WriteString(w, "\n")
+  }
+}
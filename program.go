@@ -3,14 +3,20 @@ import (
   "os"
   "flag"
   "fmt"
+  "io"
+  "reflect"
   "text/tabwriter"
   "sort"
+  "strings"
 )
 
 type Program struct {
   // Name of the program
   Name           string
 
+  // Optional short description, shown in usage
+  Description    string
+
   // Program-global options
   Options        *flag.FlagSet
 
@@ -29,33 +35,185 @@ type Program struct {
 
   // When true, os.Exit(1) is called upon failure
   ExitOnError    bool
+
+  // When true, a command can be named by any unambiguous prefix of its name or aliases,
+  // e.g. "sta" for "status"
+  PrefixMatching bool
+
+  // Groups which commands can be part of (Command.Group). Usage lists each group's commands
+  // under the group's title, in this order.
+  Groups         []CommandGroup
+
+  // When true, usage lists commands in the order they were added instead of alphabetically
+  KeepOrder      bool
+
+  // When true, options are parsed GNU-style rather than like the standard "flag" package:
+  // long options are given as --name or --name=value and single-character options, defined
+  // with a `short:"x"` field tag, as -x value or -xvalue. Boolean short options can be bundled,
+  // as in -abc.
+  GNUOptions     bool
+
+  // When true, a command's options may appear anywhere among its arguments rather than only
+  // before them, e.g. "tool build file.txt -force". "--" ends options.
+  Interspersed   bool
+
+  // When true, program options are accepted after the command name as well, e.g.
+  // "tool build -quiet" in addition to "tool -quiet build"
+  PersistentOptions bool
+
+  // When non-empty, options not given as arguments are read from environment variables named by
+  // this prefix and the option's name, e.g. "APP_FIRST_NAME" for -first-name with prefix "APP_".
+  // An `env:"NAME"` field tag sets the variable of a single option.
+  EnvPrefix      string
+
+  // When non-empty, options not given as arguments or environment variables are read from
+  // config files named after this, e.g. "tool" for ~/.config/tool/config.json. See configPaths
+  // for the locations of files. Values are keyed by command and option name, e.g. "build.jobs".
+  ConfigName     string
+
+  // When true, giving -show-config prints the values of a command's options and where they came
+  // from (Command.ShowConfig) instead of running the command, unless the command defines an
+  // option of that name
+  ShowConfigOption bool
+
+  // Named sets of option values, selected with -profile NAME. See AddProfile.
+  Profiles       []Profile
+
+  // When non-empty, names an environment variable, e.g. "TOOL_FLAGS", holding options for all
+  // commands, like "-verbose -jobs=8". Options which a command doesn't define are ignored.
+  // Options for a single command are read from a variable named after the command, e.g.
+  // "TOOL_BUILD_FLAGS" for "build". Options given as arguments take precedence.
+  DefaultArgsEnv string
+
+  // When true, a command name not matching any of Commands runs the executable
+  // "<Name>-<command>" found in PATH, if any, like git and kubectl do. The program's options are
+  // passed on in environment variables named like EnvPrefix does, e.g. "TOOL_VERBOSE".
+  ExternalCommands bool
+
+  // When true, a command name not matching any of Commands runs the plugin executable
+  // "<Name>-plugin-<command>" found in PATH, if any. Plugins are run with the argument
+  // "--cmdr-describe" to describe their options and arguments as JSON (PluginDescription), are
+  // then parsed like other commands, and are run with "--cmdr-run", receiving their parsed
+  // options and arguments as JSON on stdin (PluginInput). Plugins take precedence over
+  // ExternalCommands, which are never run with "--cmdr-describe".
+  PluginCommands bool
+
+  // When true, Main runs the command named by the base name of os.Args[0], if there's such a
+  // command, busybox-style. The program's executable can then be linked to under the names of
+  // its commands (see InstallLinksCommand) to run them directly, e.g. "ls" for "tool ls".
+  Multicall      bool
+
+  // File where ShellCommand keeps the history of commands entered. Defaults to "shell_history"
+  // in the user's config directory for the program, e.g. ~/.config/tool/shell_history.
+  HistoryFile    string
+
+  // When true, arguments of the form @path are replaced by the arguments read from the file at
+  // path, split into words like a shell does. Response files can include other response files.
+  // An argument starting with "@@" is passed on with a single "@".
+  ResponseFiles  bool
+
+  cmdOrder       []string // names of commands in the order they were added
+  options        map[string]*optionInfo
+  optionsValue   reflect.Value // pointer to options struct (SetOptions)
+  config         *config       // loaded config files (ConfigName)
+  sources        map[string]ValueSource // where option values came from (Source)
+  profile        string        // name of the selected profile (-profile)
+  inShell        bool          // running commands in a shell (ShellCommand)
+}
+
+
+type CommandGroup struct {
+  ID    string
+  Title string // e.g. "Admin commands"
 }
 
 
 // A command that shows program usage
 var HelpCommand = NewCommand("help", "Show help", func (opt *struct {
-  Command string `?`
+  Command []string `?`
 }, cmd *Command) {
-  if opt.Command == "help" {
-    fmt.Fprintf(os.Stderr, "Usage: help <command>\n")
+  if len(opt.Command) == 0 {
+    ProgramUsage(cmd.Program)
+  } else if len(opt.Command) == 1 && opt.Command[0] == "help" {
+    fmt.Fprintf(os.Stderr, "Usage: help <command>...\n")
   } else {
-    cmd2 := cmd.Program.Commands[opt.Command]
+    cmd2, _ := cmd.Program.lookupCommand(cmd.Program.Commands, opt.Command[0])
+    if cmd2 == nil && len(opt.Command) == 1 {
+      ext, err := cmd.Program.lookupExternal(opt.Command[0])
+      if err != nil {
+        cmd.Fail(err)
+      }
+      if ext != nil && ext.rawArgs {
+        // external commands describe themselves, like git's do
+        cmd.Program.externalHelp(cmd, opt.Command[0])
+        return
+      }
+      cmd2 = ext // plugins have usage like other commands
+    }
+    for i := 1; cmd2 != nil && i < len(opt.Command); i++ {
+      cmd2, _ = cmd.Program.lookupCommand(cmd2.Commands, opt.Command[i])
+    }
     if cmd2 != nil {
-      cmd2.Run(cmd.Program, []string{"-h"})
-    } else if opt.Command == "" {
-      ProgramUsage(cmd.Program)
+      pp := cmd2.Program
+      cmd2.Program = cmd.Program
+      restore := cmd2.inheritOptions(cmd.Program)
+      cmd2.Usage()
+      restore()
+      cmd2.Program = pp
     } else {
       fmt.Fprintf(os.Stderr, "%s help: unknown command \"%s\". See '%s help'\n",
-                  cmd.Program.Name, opt.Command, cmd.Program.Name)
+                  cmd.Program.Name, strings.Join(opt.Command, " "), cmd.Program.Name)
     }
   }
 })
 
 
+// Returns the names of the program's commands, sorted alphabetically or in the order they were
+// added when KeepOrder is set. Aliases are listed in parentheses after the primary name,
+// e.g. "list (ls)".
 func (p *Program) CommandNames() []string {
-  keys := make([]string, len(p.Commands))
+  names := p.orderedCommandNames(p.Commands, p.cmdOrder)
+  for i, name := range names {
+    names[i] = p.Commands[name].nameAndAliases()
+  }
+  return names
+}
+
+
+// Returns the keys of `commands` sorted alphabetically, or in the order of `order` when
+// KeepOrder is set. Commands missing from `order` are listed last, sorted alphabetically.
+func (p *Program) orderedCommandNames(commands map[string]*Command, order []string) []string {
+  sorted := commandNames(commands)
+  if p == nil || !p.KeepOrder {
+    return sorted
+  }
+  names := make([]string, 0, len(sorted))
+  seen := make(map[string]bool, len(sorted))
+  for _, name := range order {
+    if commands[name] != nil && !seen[name] {
+      names = append(names, name)
+      seen[name] = true
+    }
+  }
+  for _, name := range sorted {
+    if !seen[name] {
+      names = append(names, name)
+    }
+  }
+  return names
+}
+
+
+// Adds a command group. Groups are listed in usage in the order they were added.
+func (p *Program) AddGroup(id, title string) {
+  p.Groups = append(p.Groups, CommandGroup{id, title})
+}
+
+
+func commandNames(commands map[string]*Command) []string {
+  keys := make([]string, len(commands))
   i := 0
-  for k, _ := range p.Commands {
+  for k, _ := range commands {
     keys[i] = k
     i++
   }
@@ -65,9 +223,12 @@ func (p *Program) CommandNames() []string {
 
 
 func ProgramUsage(p *Program) {
+  if len(p.Description) != 0 {
+    os.Stderr.WriteString(p.Description + "\n")
+  }
   nflags := 0
   p.Options.VisitAll(func(f *flag.Flag) { nflags++ })
-  if nflags == 0 {
+  if nflags == 0 && !p.showConfigOption(p.Options) {
     if len(p.Commands) == 0 {
       fmt.Fprintf(os.Stderr, "Usage: %s\n", p.Name)
     } else {
@@ -81,22 +242,111 @@ func ProgramUsage(p *Program) {
     }
     p.OptionsUsage()
   }
+  w := tabwriter.NewWriter(os.Stderr, 5, 0, 2, ' ', 0)
+  p.profilesUsage(w)
   if len(p.Commands) != 0 {
-    os.Stderr.WriteString("Commands:\n")
-    w := tabwriter.NewWriter(os.Stderr, 5, 0, 2, ' ', 0)
-    for _, cmdName := range p.CommandNames() {
-      cmd := p.Commands[cmdName]
-      fmt.Fprintf(w, "  %s\t%s\n", cmd.NameAndArgs(), cmd.Description)
+    extra := "  help <cmd>\tMore information about a command\n"
+    if p.inShell {
+      extra += "  exit\tLeave the shell\n"
+    }
+    p.commandsUsage(w, p.Commands, p.cmdOrder, extra)
+  }
+  p.externalCommandsUsage(w)
+  w.Flush()
+}
+
+
+// Lists `commands`, sectioned by group. Commands that are not part of a group are listed last,
+// followed by `extra`. Headings and lines are written to w which is expected to be a tabwriter.
+func (p *Program) commandsUsage(
+  w io.Writer, commands map[string]*Command, order []string, extra string,
+) {
+  var groups []CommandGroup
+  if p != nil {
+    groups = p.Groups
+  }
+  sections := make(map[string][]*Command)
+  for _, cmdName := range p.orderedCommandNames(commands, order) {
+    cmd := commands[cmdName]
+    if cmd.Hidden {
+      continue
     }
-    fmt.Fprint(w, "  help <cmd>\tMore information about a command\n")
-    w.Flush()
+    if _, ok := sections[cmd.Group]; !ok && cmd.Group != "" && !hasGroup(groups, cmd.Group) {
+      // group not registered with the program; use its ID as the title
+      groups = append(groups, CommandGroup{cmd.Group, cmd.Group})
+    }
+    sections[cmd.Group] = append(sections[cmd.Group], cmd)
+  }
+
+  title := "Commands"
+  for _, g := range groups {
+    if len(sections[g.ID]) == 0 {
+      continue
+    }
+    fmt.Fprintf(w, "%s:\n", g.Title)
+    for _, cmd := range sections[g.ID] {
+      cmd.usageLine(w)
+    }
+    title = "Other commands"
+  }
+
+  if len(sections[""]) != 0 || extra != "" {
+    fmt.Fprintf(w, "%s:\n", title)
+    for _, cmd := range sections[""] {
+      cmd.usageLine(w)
+    }
+    io.WriteString(w, extra)
   }
 }
 
 
+func hasGroup(groups []CommandGroup, id string) bool {
+  for _, g := range groups {
+    if g.ID == id {
+      return true
+    }
+  }
+  return false
+}
+
+
 // Print options with their default values
 func (p *Program) OptionsUsage() {
-  optionsUsage(p.Options)
+  optionsUsage(p, p.Options, p.options, false)
+}
+
+
+// Binds the fields of the struct pointed to by `v` as program options, the same way as
+// NewCommand binds the options of a command. Commands can receive `v` by taking a parameter of
+// its type after their options struct, e.g. func(opt *struct{...}, g *GlobalOptions).
+func (p *Program) SetOptions(v interface{}) {
+  ptr := reflect.ValueOf(v)
+  if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+    panic("SetOptions expects a pointer to a struct")
+  }
+  if p.Options == nil {
+    p.Options = flag.NewFlagSet(p.Name, flag.ContinueOnError)
+  }
+  // bind through a nameless command which represents the program
+  cmd := &Command{Options: p.Options, Program: p, options: p.options}
+  cmd.bindStruct(ptr)
+  if len(cmd.Args) != 0 || cmd.VarArgs != nil {
+    panic("program options can't have arguments")
+  }
+  p.options = cmd.options
+  p.optionsValue = ptr
+}
+
+
+// Adds the flags of fs to the program's options, except for flags with the same names as
+// options the program already has. The flags' values are shared with fs.
+func (p *Program) ImportFlags(fs *flag.FlagSet) {
+  fs.VisitAll(func(f *flag.Flag) {
+    if p.Options.Lookup(f.Name) == nil {
+      p.Options.Var(f.Value, f.Name, f.Usage)
+      p.Options.Lookup(f.Name).DefValue = f.DefValue
+    }
+  })
 }
 
 
@@ -106,35 +356,155 @@ func (p *Program) AddCommand(cmd *Command) {
   if p.Commands == nil {
     p.Commands = make(map[string]*Command)
   }
+  if p.Commands[cmd.Name] == nil {
+    p.cmdOrder = append(p.cmdOrder, cmd.Name)
+  }
   p.Commands[cmd.Name] = cmd
 }
 
 
-// Parses args and returns the matching command
+// Finds the command in `commands` with the name or alias `name`. If there's no such command and
+// PrefixMatching is enabled, the command with a name or alias starting with `name` is returned.
+// When `name` is an ambiguous prefix, nil is returned together with the candidates' names.
+func (p *Program) lookupCommand(commands map[string]*Command, name string) (*Command, []string) {
+  if cmd := commands[name]; cmd != nil {
+    return cmd, nil
+  }
+  for _, cmdName := range commandNames(commands) {
+    cmd := commands[cmdName]
+    for _, alias := range cmd.Aliases {
+      if alias == name {
+        return cmd, nil
+      }
+    }
+  }
+  if !p.PrefixMatching || name == "" {
+    return nil, nil
+  }
+  var match *Command
+  var candidates []string
+  for _, cmdName := range commandNames(commands) {
+    cmd := commands[cmdName]
+    if cmd.Hidden {
+      continue
+    }
+    if strings.HasPrefix(cmdName, name) {
+      candidates = append(candidates, cmdName)
+      match = cmd
+      continue
+    }
+    for _, alias := range cmd.Aliases {
+      if strings.HasPrefix(alias, name) {
+        candidates = append(candidates, cmdName)
+        match = cmd
+        break
+      }
+    }
+  }
+  if len(candidates) != 1 {
+    return nil, candidates
+  }
+  return match, nil
+}
+
+
+// Makes the commands of another program available as subcommands of a new command `name`,
+// which is added to this program and returned. The global options of `child` become options of
+// the new command which are inherited by all of its subcommands, and the default command of
+// `child`, if any, is run when no subcommand is named. The commands of `child` are copied, so
+// that they're left as they are in `child`.
+func (p *Program) Mount(name string, child *Program) *Command {
+  cmd := NewCommand(name, child.Description, nil)
+  cmd.mounted = child
+  for _, name := range child.orderedCommandNames(child.Commands, child.cmdOrder) {
+    cmd.AddCommand(child.Commands[name].clone())
+  }
+  if child.DefaultCommand != nil {
+    cmd.main = func(cmd *Command) {
+      child.DefaultCommand.Run(cmd.Program, cmd.Options.Args())
+    }
+  }
+  p.AddCommand(cmd)
+  return cmd
+}
+
+
+// Parses args and returns the matching command. When the command has subcommands, the
+// innermost subcommand named by args is returned. Response files in args are expanded here
+// (ResponseFiles), once, so cmdArgs are ready for Command.Parse and Command.Run, which don't
+// expand them again.
 func (p *Program) Parse(args []string) (cmd *Command, cmdArgs []string) {
-  p.Options.Usage = func() { p.Usage(p) }
-  defer func(){ p.Options.Usage = nil }()
-  if err := p.Options.Parse(args); err != nil {
-    panic(err)
+  if _, names := p.allProfiles(); len(names) != 0 {
+    p.defineProfileOption()
+  }
+  args, err := p.expandArgs(args)
+  if err == nil {
+    err = parseOptions(p, p.Options, p.options, args, false)
+  }
+  if err == nil || err == errShowConfig {
+    sources, err2 := p.setUnsetOptions(p.Options, p.options, "", nil)
+    if err2 != nil {
+      err = err2
+    }
+    p.sources = sources
+  }
+  if err != nil {
+    if err == errShowConfig {
+      p.ShowConfig(os.Stdout)
+      if p.ExitOnError {
+        os.Exit(0)
+      }
+    } else if err == flag.ErrHelp {
+      p.Usage(p)
+      if p.ExitOnError {
+        os.Exit(0)
+      }
+    } else {
+      see := p.Name + " " + p.dash("help")
+      fmt.Fprintf(os.Stderr, "%s: %s\n", p.Name, seeHelp(err.Error(), see))
+      if p.ExitOnError {
+        os.Exit(2)
+      }
+    }
+    return nil, args
   }
   remainingArgs := p.Options.Args()
-  if len(remainingArgs) == 0 || len(p.Commands) == 0 {
+  if len(remainingArgs) == 0 ||
+     (len(p.Commands) == 0 && !p.ExternalCommands && !p.PluginCommands) {
     // No command specified
     if p.DefaultCommand != nil {
-      return p.DefaultCommand, remainingArgs[1:]
+      return p.DefaultCommand, remainingArgs
     }
     fmt.Fprintf(os.Stderr, "%s: no command specified\n", p.Name)
     p.Usage(p)
+  } else if remainingArgs, cmd, err = p.expandAlias(remainingArgs); err != nil {
+    fmt.Fprintf(os.Stderr, "%s: %v\n", p.Name, err)
+  } else if cmd != nil {
+    // shell alias
+    return cmd, remainingArgs[1:]
   } else {
     cmdName := remainingArgs[0]
-    cmd := p.Commands[cmdName]
+    cmd, candidates := p.lookupCommand(p.Commands, cmdName)
     if cmd == nil && cmdName == "help" {
       cmd = HelpCommand
     }
-    if cmd != nil {
-      return cmd, remainingArgs[1:]
+    if cmd == nil && len(candidates) == 0 {
+      if cmd, err = p.lookupExternal(cmdName); cmd != nil {
+        return cmd, remainingArgs[1:]
+      }
+    }
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "%s: %v\n", p.Name, err)
+    } else if cmd != nil {
+      cmd, cmdArgs = p.parseSubcommand(cmd, remainingArgs[1:])
+      if cmd != nil {
+        return cmd, cmdArgs
+      }
+    } else {
+      names := append([]string{"help"}, p.externalCommandNames()...)
+      msg := commandLookupError(p.Commands, cmdName, candidates, names...)
+      fmt.Fprintf(os.Stderr, "%s: %s\n", p.Name, seeHelp(msg, p.Name + " help"))
     }
-    fmt.Fprintf(os.Stderr, "%s: unknown command \"%s\". See '%s help'\n", p.Name, cmdName, p.Name)
   }
   if p.ExitOnError {
     os.Exit(1)
@@ -143,8 +513,96 @@ func (p *Program) Parse(args []string) (cmd *Command, cmdArgs []string) {
 }
 
 
+// Descends into the subcommands of cmd as named by args. Options preceding a subcommand name
+// are parsed by the command owning the subcommand. Returns nil if no command could be resolved.
+func (p *Program) parseSubcommand(cmd *Command, args []string) (*Command, []string) {
+  for len(cmd.Commands) != 0 {
+    subcmd, subcmdArgs, ok := p.parseParentOptions(cmd, args)
+    if !ok {
+      return nil, subcmdArgs
+    }
+    if subcmd == nil {
+      // arguments to cmd itself
+      return cmd, args
+    }
+    cmd, args = subcmd, subcmdArgs
+  }
+  return cmd, args
+}
+
+
+// Parses the options of cmd preceding the name of one of its subcommands in args, returning
+// the subcommand and its arguments. The subcommand is nil when args are arguments to cmd itself.
+// Returns false if there's no such subcommand or the options are invalid, after reporting why.
+func (p *Program) parseParentOptions(cmd *Command, args []string) (*Command, []string, bool) {
+  pp := cmd.Program
+  cmd.Program = p
+  defer func(){ cmd.Program = pp }()
+  defer cmd.inheritOptions(p)()
+  err := parseOptions(p, cmd.Options, cmd.options, args, false)
+  if err == nil || err == errShowConfig {
+    sources, err2 := p.setUnsetOptions(cmd.Options, cmd.options, configKeyPrefix(cmd), nil)
+    if err2 != nil {
+      err = err2
+    }
+    cmd.sources = sources
+  }
+  if err != nil {
+    cmd.parseError(err)
+    return nil, args, false
+  }
+  remainingArgs := cmd.Options.Args()
+  if len(remainingArgs) == 0 {
+    if cmd.main != nil {
+      return nil, args, true
+    }
+    fmt.Fprintf(os.Stderr, "%s %s: no command specified\n", p.Name, cmd.Path())
+    cmd.Usage()
+    return nil, remainingArgs, false
+  }
+  subcmd, candidates := p.lookupCommand(cmd.Commands, remainingArgs[0])
+  if subcmd == nil {
+    if cmd.main != nil {
+      return nil, args, true
+    }
+    msg := commandLookupError(cmd.Commands, remainingArgs[0], candidates)
+    fmt.Fprintf(os.Stderr, "%s %s: %s\n",
+                p.Name, cmd.Path(), seeHelp(msg, p.Name + " help " + cmd.Path()))
+    return nil, remainingArgs, false
+  }
+  return subcmd, remainingArgs[1:], true
+}
+
+
+// Describes why `name` didn't match any of `commands`, which is either because it's an
+// ambiguous prefix of `candidates` or because it's unknown. In the latter case, similar
+// command names and aliases are suggested, including any `implicit` command names.
+func commandLookupError(
+  commands map[string]*Command, name string, candidates []string, implicit ...string,
+) string {
+  if len(candidates) != 0 {
+    return fmt.Sprintf("ambiguous command \"%s\" could be %s", name, strings.Join(candidates, ", "))
+  }
+  names := implicit
+  for _, cmdName := range commandNames(commands) {
+    if !commands[cmdName].Hidden {
+      names = append(names, cmdName)
+      names = append(names, commands[cmdName].Aliases...)
+    }
+  }
+  if hint := didYouMean(suggest(name, names), "%q"); hint != "" {
+    return fmt.Sprintf("unknown command \"%s\"; %s", name, hint)
+  }
+  return fmt.Sprintf("unknown command \"%s\"", name)
+}
+
+
 // Parses args and runs a command. Returns the command run.
+// With Multicall set, the command named by the base name of os.Args[0] is run, if any.
 func (p *Program) Main(args []string) *Command {
+  if name := p.multicallCommand(); name != "" {
+    args = append([]string{name}, args...)
+  }
   cmd, cmdArgs := p.Parse(args)
   if cmd != nil {
     cmd.Run(p, cmdArgs)
@@ -0,0 +1,76 @@
+package cmdr
+import (
+  "flag"
+  "testing"
+)
+
+
+func newTestProgram(name string) *Program {
+  return &Program{
+    Name:    name,
+    Options: flag.NewFlagSet(name, flag.ContinueOnError),
+    Usage:   func(*Program) {},
+  }
+}
+
+
+func TestPersistentOptionsOfSharedCommand(t *testing.T) {
+  var quietA, quietB bool
+  a, b := newTestProgram("a"), newTestProgram("b")
+  a.Options.BoolVar(&quietA, "quiet", false, "Say less")
+  b.Options.BoolVar(&quietB, "quiet", false, "Say less")
+  a.PersistentOptions, b.PersistentOptions = true, true
+  run := NewCommand("run", "Run", func(opt *struct{}) {})
+  a.AddCommand(run)
+  b.AddCommand(run)
+
+  b.Main([]string{"run", "-quiet"})
+  if quietA || !quietB {
+    t.Errorf("b run -quiet: quiet is %v in a and %v in b, want false and true", quietA, quietB)
+  }
+  quietB = false
+  a.Main([]string{"run", "-quiet"})
+  if !quietA || quietB {
+    t.Errorf("a run -quiet: quiet is %v in a and %v in b, want true and false", quietA, quietB)
+  }
+  quietA = false
+  b.Main([]string{"help", "-quiet"})
+  if quietA || !quietB {
+    t.Errorf("b help -quiet: quiet is %v in a and %v in b, want false and true", quietA, quietB)
+  }
+  if run.Options.Lookup("quiet") != nil || HelpCommand.Options.Lookup("quiet") != nil {
+    t.Errorf("program options were added to the options of shared commands")
+  }
+}
+
+
+func TestMount(t *testing.T) {
+  var host string
+  child := newTestProgram("dbtool")
+  child.Options.StringVar(&host, "host", "localhost", "Database host")
+  var ran []string
+  migrate := NewCommand("migrate", "Migrate", func(opt *struct {
+    Steps string `? Steps to migrate`
+  }, cmd *Command) {
+    ran = append(ran, cmd.Path() + " " + opt.Steps + " " + host)
+  })
+  child.AddCommand(migrate)
+
+  p := newTestProgram("tool")
+  p.Mount("db", child)
+  p.Main([]string{"db", "migrate", "-host", "h1", "2"})
+  p.Main([]string{"db", "-host", "h2", "migrate", "3"})
+  child.Main([]string{"-host", "h3", "migrate", "4"})
+  want := []string{"db migrate 2 h1", "db migrate 3 h2", "migrate 4 h3"}
+  if len(ran) != len(want) {
+    t.Fatalf("ran %q, want %q", ran, want)
+  }
+  for i := range want {
+    if ran[i] != want[i] {
+      t.Errorf("ran %q, want %q", ran[i], want[i])
+    }
+  }
+  if migrate.Parent != nil || migrate.Options.Lookup("host") != nil {
+    t.Errorf("mounting changed the child program's command")
+  }
+}
@@ -0,0 +1,76 @@
+package cmdr
+import (
+  "fmt"
+  "io/ioutil"
+)
+
+
+// Maximum depth of response files including other response files
+const maxResponseFileDepth = 10
+
+
+// Replaces each "@path" argument with the words of the file at path (Program.ResponseFiles).
+// Words in response files are split like a shell does (see splitWords) and can themselves be
+// "@path" arguments. "@@" at the start of an argument stands for a literal "@". Expansion stops
+// at a "--" argument, also when read from a response file, after which arguments are passed on
+// as is. Returns true if expansion stopped at "--".
+func expandResponseFiles(args []string, depth int) ([]string, bool, error) {
+  var expanded []string
+  for i, arg := range args {
+    if arg == "--" {
+      if expanded == nil {
+        return args, true, nil
+      }
+      return append(expanded, args[i:]...), true, nil
+    }
+    if len(arg) < 2 || arg[0] != '@' {
+      if expanded != nil {
+        expanded = append(expanded, arg)
+      }
+      continue
+    }
+    if expanded == nil {
+      // first argument that changes; copy the ones before it
+      expanded = append([]string{}, args[:i]...)
+    }
+    if arg[1] == '@' {
+      expanded = append(expanded, arg[1:])
+      continue
+    }
+    path := arg[1:]
+    if depth == maxResponseFileDepth {
+      return nil, false, fmt.Errorf("%s: response files nested too deeply", path)
+    }
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+      return nil, false, err
+    }
+    words, err := splitWords(string(data))
+    if err != nil {
+      return nil, false, fmt.Errorf("%s: %v", path, err)
+    }
+    words, end, err := expandResponseFiles(words, depth + 1)
+    if err != nil {
+      return nil, false, err
+    }
+    expanded = append(expanded, words...)
+    if end {
+      return append(expanded, args[i+1:]...), true, nil
+    }
+  }
+  if expanded == nil {
+    return args, false, nil
+  }
+  return expanded, false, nil
+}
+
+
+// Expands response files in args if p has ResponseFiles set
+func (p *Program) expandArgs(args []string) ([]string, error) {
+  if p == nil || !p.ResponseFiles {
+    return args, nil
+  }
+  args, _, err := expandResponseFiles(args, 0)
+  return args, err
+}
+
@@ -0,0 +1,59 @@
+package cmdr
+import (
+  "io/ioutil"
+  "path/filepath"
+  "reflect"
+  "testing"
+)
+
+
+func TestResponseFiles(t *testing.T) {
+  dir := t.TempDir()
+  ioutil.WriteFile(filepath.Join(dir, "args"), []byte("-verbose 'a b' @@lit"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "lit"), []byte("not read"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "end"), []byte("x -- @y"), 0644)
+  at := func(name string) string { return "@" + filepath.Join(dir, name) }
+
+  var got []string
+  var verbose bool
+  p := newTestProgram("tool")
+  p.ResponseFiles = true
+  p.AddCommand(NewCommand("build", "Build", func(opt *struct {
+    Verbose bool     `Print more`
+    Files   []string `? Files`
+  }) {
+    got, verbose = opt.Files, opt.Verbose
+  }))
+  p.AddCommand(NewCommand("x", "", func(opt *struct {
+    Files []string `? Files`
+  }) {
+    got = opt.Files
+  }))
+
+  tests := []struct {
+    args  []string
+    files []string
+  }{
+    {[]string{"build", at("args")}, []string{"a b", "@lit"}},
+    {[]string{at("end"), at("args")}, []string{"@y", at("args")}},
+    {[]string{"build", "--", at("args")}, []string{at("args")}},
+    {[]string{"build", "@@x", at("end")}, []string{"@x", "x", "--", "@y"}},
+  }
+  for _, test := range tests {
+    got = nil
+    cmd, args := p.Parse(test.args)
+    if cmd == nil {
+      t.Fatalf("%q: no command", test.args)
+    }
+    if err := cmd.Parse(args); err != nil {
+      t.Fatalf("%q: %v", test.args, err)
+    }
+    cmd.main(cmd)
+    if !reflect.DeepEqual(got, test.files) {
+      t.Errorf("%q: arguments %q, want %q", test.args, got, test.files)
+    }
+  }
+  if !verbose {
+    t.Errorf("-verbose in a response file was not given")
+  }
+}
@@ -0,0 +1,257 @@
+package cmdr
+import (
+  "flag"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+
+// A command that reads and runs the program's commands interactively (see runShell)
+var ShellCommand = NewCommand("shell", "Run commands interactively",
+func (opt *struct{}, cmd *Command) {
+  cmd.Program.runShell()
+})
+
+
+// Maximum number of lines loaded from the history file
+const maxShellHistory = 1000
+
+
+// Reads lines from stdin, split into arguments like a shell does, and runs them like the
+// program's arguments, without exiting on errors. Lines are kept in a history file
+// (HistoryFile), and command and option names are completed with tab on a terminal.
+func (p *Program) runShell() {
+  if p.inShell {
+    fmt.Fprintf(os.Stderr, "%s: already in the shell\n", p.Name)
+    return
+  }
+  exitOnError := p.ExitOnError
+  p.inShell, p.ExitOnError = true, false
+  defer func() {
+    p.inShell, p.ExitOnError = false, exitOnError
+  }()
+  reset := p.shellResetter()
+
+  e := newLineEditor(int(os.Stdin.Fd()), p.shellCompletions)
+  historyPath := p.historyPath()
+  if historyPath != "" {
+    e.history = readHistory(historyPath)
+  }
+  prompt := filepath.Base(p.Name) + "> "
+  for {
+    line, err := e.readLine(prompt)
+    if err != nil {
+      break
+    }
+    args, err := splitWords(line)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "%s: %v\n", p.Name, err)
+      continue
+    }
+    if len(args) == 0 {
+      continue
+    }
+    if e.addHistory(line) && historyPath != "" {
+      appendHistory(historyPath, line)
+    }
+    if (args[0] == "exit" || args[0] == "quit") && !p.hasCommand(args[0]) {
+      break
+    }
+    reset()
+    p.runShellLine(args)
+  }
+}
+
+
+// Parses and runs the command of a line entered in the shell. The command runs in a goroutine
+// of its own, which Command.Fail ends rather than exiting the program, like testing.T.FailNow
+// ends a test. Program options are parsed into a copy of p.Options, as command options are
+// (see Command.inheritOptions), so that options given on one line aren't seen as given on the
+// next.
+func (p *Program) runShellLine(args []string) {
+  options := p.Options
+  if options != nil {
+    p.Options = flag.NewFlagSet(options.Name(), flag.ContinueOnError)
+    p.Options.Usage = options.Usage
+    options.VisitAll(func(f *flag.Flag) { copyFlag(p.Options, f) })
+  }
+  defer func() { p.Options = options }()
+  done := make(chan struct{})
+  go func() {
+    defer close(done)
+    if cmd, cmdArgs := p.Parse(args); cmd != nil {
+      cmd.Run(p, cmdArgs)
+    }
+  }()
+  <-done
+}
+
+
+// Returns a function which restores the options and arguments of p and its commands to their
+// current values, so that each line entered in the shell starts from the same state
+func (p *Program) shellResetter() func() {
+  type savedValue struct {
+    value flag.Value
+    s     string
+  }
+  var values []savedValue
+  var resets []func()
+  save := func(fs *flag.FlagSet) {
+    if fs == nil {
+      return
+    }
+    fs.VisitAll(func(f *flag.Flag) {
+      _, isDeprecated := f.Value.(*deprecatedValue)
+      _, isSlice := f.Value.(SliceBinding) // restored by Command.reset
+      if !isDeprecated && !isSlice {
+        values = append(values, savedValue{f.Value, f.Value.String()})
+      }
+    })
+  }
+  var saveCommand func(*Command)
+  saveCommand = func(cmd *Command) {
+    save(cmd.Options)
+    if cmd.mounted != nil {
+      save(cmd.mounted.Options)
+    }
+    if cmd.reset != nil {
+      resets = append(resets, cmd.reset)
+    }
+    for _, subcmd := range cmd.Commands {
+      saveCommand(subcmd)
+    }
+  }
+  save(p.Options)
+  for _, cmd := range p.Commands {
+    saveCommand(cmd)
+  }
+  saveCommand(HelpCommand)
+  if p.DefaultCommand != nil {
+    saveCommand(p.DefaultCommand)
+  }
+
+  return func() {
+    for _, reset := range resets {
+      reset()
+    }
+    for _, v := range values {
+      v.value.Set(v.s)
+    }
+  }
+}
+
+
+// Returns the candidates for completing the last word of `line`, along with the index in line
+// at which the word starts
+func (p *Program) shellCompletions(line string) (int, []string) {
+  start := strings.LastIndexAny(line, " \t") + 1
+  word := line[start:]
+  words := strings.Fields(line[:start])
+
+  // find the command named by words
+  var cmd *Command
+  i := 0
+  for i < len(words) && strings.HasPrefix(words[i], "-") {
+    i++ // program option
+  }
+  help := i < len(words) && words[i] == "help" && p.Commands["help"] == nil
+  if help {
+    i++
+  }
+  commands := p.Commands
+  for ; i < len(words); i++ {
+    if strings.HasPrefix(words[i], "-") {
+      continue
+    }
+    next, _ := p.lookupCommand(commands, words[i])
+    if next == nil && cmd == nil {
+      next, _ = p.lookupExternal(words[i]) // a plugin, completing its options
+    }
+    if next == nil {
+      break
+    }
+    cmd, commands = next, next.Commands
+  }
+
+  var names []string
+  if strings.HasPrefix(word, "-") && !help {
+    fs, options := p.Options, p.options
+    if cmd != nil {
+      fs, options = cmd.Options, cmd.options
+    }
+    if fs != nil {
+      fs.VisitAll(func(f *flag.Flag) {
+        if !options[f.Name].isHidden() {
+          names = append(names, p.dash(f.Name))
+        }
+      })
+    }
+  } else if cmd == nil || help || len(commands) != 0 {
+    for name, c := range commands {
+      if !c.Hidden {
+        names = append(names, name)
+      }
+    }
+    if cmd == nil {
+      names = append(names, p.externalCommandNames()...)
+    }
+    if cmd == nil && !help {
+      names = append(names, "help", "exit")
+    }
+  }
+
+  var candidates []string
+  for _, name := range names {
+    if strings.HasPrefix(name, word) {
+      candidates = append(candidates, name)
+    }
+  }
+  sort.Strings(candidates)
+  return start, candidates
+}
+
+
+// Returns the path of the shell's history file, or "" if there's none
+func (p *Program) historyPath() string {
+  if p.HistoryFile != "" {
+    return p.HistoryFile
+  }
+  dir := userConfigDir()
+  if dir == "" {
+    return ""
+  }
+  name := p.ConfigName
+  if name == "" {
+    name = filepath.Base(p.Name)
+  }
+  return filepath.Join(dir, name, "shell_history")
+}
+
+
+// Returns the last lines of the history file at path
+func readHistory(path string) []string {
+  data, err := ioutil.ReadFile(path)
+  if err != nil || len(data) == 0 {
+    return nil
+  }
+  lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+  if len(lines) > maxShellHistory {
+    lines = lines[len(lines) - maxShellHistory:]
+  }
+  return lines
+}
+
+
+func appendHistory(path, line string) {
+  os.MkdirAll(filepath.Dir(path), 0755)
+  f, err := os.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0600)
+  if err != nil {
+    return
+  }
+  f.WriteString(line + "\n")
+  f.Close()
+}
@@ -0,0 +1,112 @@
+package cmdr
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "text/tabwriter"
+)
+
+
+// Where the value of an option came from
+type ValueSource struct {
+  Kind ValueSourceKind
+  Name string // name of the environment variable or profile, or path of the config file
+  Line int    // line in the config file, or 0 if unknown
+}
+
+type ValueSourceKind int
+const (
+  SourceDefault ValueSourceKind = iota // option has its default value
+  SourceArgs                           // given on the command line
+  SourceEnv                            // read from an environment variable (EnvPrefix)
+  SourceConfig                         // read from a config file (ConfigName)
+  SourceProfile                        // set by the profile Name (-profile)
+)
+
+
+// Returns e.g. "default", "command line", "profile staging", "$APP_JOBS" or
+// "/home/me/.config/app/config.json:3"
+func (s ValueSource) String() string {
+  switch s.Kind {
+  case SourceArgs:
+    return "command line"
+  case SourceEnv:
+    return "$" + s.Name
+  case SourceProfile:
+    return "profile " + s.Name
+  case SourceConfig:
+    if s.Line > 0 {
+      return fmt.Sprintf("%s:%d", s.Name, s.Line)
+    }
+    return s.Name
+  }
+  return "default"
+}
+
+
+// Returned by parseOptions when -show-config is given
+var errShowConfig = errors.New("show config")
+
+
+// Returns true if -show-config is accepted along with the options of fs (ShowConfigOption)
+func (p *Program) showConfigOption(fs *flag.FlagSet) bool {
+  return p != nil && p.ShowConfigOption && (fs == nil || fs.Lookup("show-config") == nil)
+}
+
+
+// Returns where the current value of the option `name` came from. Only meaningful after the
+// command's arguments have been parsed.
+func (cmd *Command) Source(name string) ValueSource {
+  if s, ok := cmd.sources[name]; ok {
+    return s
+  }
+  if info := cmd.options[name]; info != nil && info.inherited {
+    // set while parsing the options of a mounted program's command, or the program's options
+    for c := cmd.Parent; c != nil; c = c.Parent {
+      if c.mounted != nil && c.mounted.Options != nil && c.mounted.Options.Lookup(name) != nil {
+        return c.sources[name]
+      }
+    }
+    if cmd.Program != nil {
+      return cmd.Program.Source(name)
+    }
+  }
+  return ValueSource{}
+}
+
+
+// Returns where the current value of the program option `name` came from
+func (p *Program) Source(name string) ValueSource {
+  return p.sources[name]
+}
+
+
+// Writes each of the command's options with its value and where the value came from.
+// This is what -show-config prints.
+func (cmd *Command) ShowConfig(w io.Writer) {
+  writeConfig(w, cmd.Program, cmd.Options, cmd.Source)
+}
+
+
+// Writes each of the program's options with its value and where the value came from.
+// This is what -show-config prints when given before a command name.
+func (p *Program) ShowConfig(w io.Writer) {
+  writeConfig(w, p, p.Options, p.Source)
+}
+
+
+func writeConfig(w io.Writer, p *Program, fs *flag.FlagSet, source func(string) ValueSource) {
+  tw := tabwriter.NewWriter(w, 5, 0, 3, ' ', 0)
+  fs.VisitAll(func(f *flag.Flag) {
+    if _, ok := f.Value.(*deprecatedValue); ok {
+      return
+    }
+    value := f.Value.String()
+    if flagIsString(f) {
+      value = fmt.Sprintf("%q", value)
+    }
+    fmt.Fprintf(tw, "%s\t%s\t%s\n", p.dash(f.Name), value, source(f.Name))
+  })
+  tw.Flush()
+}
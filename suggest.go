@@ -0,0 +1,74 @@
+package cmdr
+import (
+  "fmt"
+  "strings"
+)
+
+
+// Returns the candidates closest to `name` by edit distance, or nil if none of the candidates
+// are similar enough to be a likely misspelling of `name`.
+func suggest(name string, candidates []string) []string {
+  maxDist := (len(name) + 2) / 3
+  var matches []string
+  for _, candidate := range candidates {
+    d := editDistance(name, candidate)
+    if d > maxDist {
+      continue
+    }
+    if d < maxDist {
+      maxDist = d
+      matches = matches[:0]
+    }
+    matches = append(matches, candidate)
+  }
+  return matches
+}
+
+
+// Formats a "did you mean" hint for suggestions, e.g. `did you mean "status"?`.
+// `format` is used to format each suggestion, e.g. "%q" or "-%s".
+func didYouMean(suggestions []string, format string) string {
+  if len(suggestions) == 0 {
+    return ""
+  }
+  quoted := make([]string, len(suggestions))
+  for i, s := range suggestions {
+    quoted[i] = fmt.Sprintf(format, s)
+  }
+  return "did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
+
+// Damerau-Levenshtein distance (optimal string alignment) between a and b, counting insertions,
+// deletions, substitutions and transpositions of adjacent characters as one edit each.
+func editDistance(a, b string) int {
+  ar, br := []rune(a), []rune(b)
+  d := make([][]int, len(ar)+1)
+  for i := range d {
+    d[i] = make([]int, len(br)+1)
+    d[i][0] = i
+  }
+  for j := range d[0] {
+    d[0][j] = j
+  }
+  for i := 1; i <= len(ar); i++ {
+    for j := 1; j <= len(br); j++ {
+      cost := 1
+      if ar[i-1] == br[j-1] {
+        cost = 0
+      }
+      d[i][j] = min3(d[i-1][j] + 1, d[i][j-1] + 1, d[i-1][j-1] + cost)
+      if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] && d[i-2][j-2] + 1 < d[i][j] {
+        d[i][j] = d[i-2][j-2] + 1
+      }
+    }
+  }
+  return d[len(ar)][len(br)]
+}
+
+
+func min3(a, b, c int) int {
+  if b < a { a = b }
+  if c < a { a = c }
+  return a
+}
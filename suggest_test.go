@@ -0,0 +1,57 @@
+package cmdr
+import (
+  "reflect"
+  "testing"
+)
+
+
+func TestEditDistance(t *testing.T) {
+  tests := []struct {
+    a, b string
+    dist int
+  }{
+    {"", "", 0},
+    {"", "abc", 3},
+    {"abc", "", 3},
+    {"status", "status", 0},
+    {"stats", "status", 1},          // insertion
+    {"statuss", "status", 1},        // deletion
+    {"stetus", "status", 1},         // substitution
+    {"frist-name", "first-name", 1}, // transposition
+    {"hlep", "help", 1},
+    {"abc", "cba", 2},
+    {"kitten", "sitting", 3},
+    {"añb", "anb", 1},               // counts characters, not bytes
+  }
+  for _, test := range tests {
+    if dist := editDistance(test.a, test.b); dist != test.dist {
+      t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, dist, test.dist)
+    }
+  }
+}
+
+
+func TestSuggest(t *testing.T) {
+  candidates := []string{"status", "stash", "build", "first-name", "last-name"}
+  tests := []struct {
+    name        string
+    suggestions []string
+  }{
+    {"stats", []string{"status"}},
+    {"stas", []string{"stash"}},
+    {"stat", []string{"status", "stash"}},
+    {"biuld", []string{"build"}},
+    {"frist-name", []string{"first-name"}},
+    {"xyz", nil},
+    {"b", nil},
+  }
+  for _, test := range tests {
+    suggestions := suggest(test.name, candidates)
+    if len(suggestions) == 0 && len(test.suggestions) == 0 {
+      continue
+    }
+    if !reflect.DeepEqual(suggestions, test.suggestions) {
+      t.Errorf("suggest(%q) = %q, want %q", test.name, suggestions, test.suggestions)
+    }
+  }
+}
@@ -0,0 +1,10 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package cmdr
+import "syscall"
+
+const (
+  ioctlGetTermios = syscall.TIOCGETA
+  ioctlSetTermios = syscall.TIOCSETA
+)
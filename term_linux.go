@@ -0,0 +1,7 @@
+package cmdr
+import "syscall"
+
+const (
+  ioctlGetTermios = syscall.TCGETS
+  ioctlSetTermios = syscall.TCSETS
+)
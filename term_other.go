@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package cmdr
+import "errors"
+
+
+// Line editing isn't supported on this platform; lines are read as-is
+func makeRaw(fd int) (func(), error) {
+  return nil, errors.New("terminal not supported")
+}
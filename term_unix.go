@@ -0,0 +1,40 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package cmdr
+import (
+  "syscall"
+  "unsafe"
+)
+
+
+// Puts the terminal `fd` in raw mode, returning a function which restores its previous mode.
+// Fails if fd isn't a terminal.
+func makeRaw(fd int) (func(), error) {
+  var old syscall.Termios
+  if err := ioctlTermios(fd, ioctlGetTermios, &old); err != nil {
+    return nil, err
+  }
+  raw := old
+  raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+                syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+  raw.Oflag &^= syscall.OPOST
+  raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+  raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+  raw.Cflag |= syscall.CS8
+  raw.Cc[syscall.VMIN] = 1
+  raw.Cc[syscall.VTIME] = 0
+  if err := ioctlTermios(fd, ioctlSetTermios, &raw); err != nil {
+    return nil, err
+  }
+  return func() { ioctlTermios(fd, ioctlSetTermios, &old) }, nil
+}
+
+
+func ioctlTermios(fd int, req uintptr, t *syscall.Termios) error {
+  _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+  if errno != 0 {
+    return errno
+  }
+  return nil
+}
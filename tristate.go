@@ -0,0 +1,60 @@
+package cmdr
+import (
+  "fmt"
+  "strconv"
+)
+
+
+// TriState is an option value which is either true, false or automatically decided by the
+// program, e.g. for a -color option. The zero value is TriAuto. As an option, it can be given
+// without a value to mean TriTrue, e.g. "-color", or with a value, e.g. "-color=never".
+type TriState int
+
+const (
+  TriAuto TriState = iota
+  TriTrue
+  TriFalse
+)
+
+func (t TriState) String() string {
+  switch t {
+    case TriTrue:  return "true"
+    case TriFalse: return "false"
+    default:       return "auto"
+  }
+}
+
+// Sets t from a string: "auto", or anything accepted by strconv.ParseBool, "always", "yes",
+// "on", "never", "no" or "off"
+func (t *TriState) Set(s string) error {
+  switch s {
+    case "auto":                *t = TriAuto; return nil
+    case "always", "yes", "on": *t = TriTrue; return nil
+    case "never", "no", "off":  *t = TriFalse; return nil
+  }
+  b, err := strconv.ParseBool(s)
+  if err != nil {
+    return fmt.Errorf("expected true, false or auto")
+  }
+  if b {
+    *t = TriTrue
+  } else {
+    *t = TriFalse
+  }
+  return nil
+}
+
+func (t *TriState) Get() interface{} { return *t }
+func (t *TriState) ImplicitValue() string { return "true" }
+
+// Makes a TriState accepted without a value by the standard "flag" package as well
+func (t *TriState) IsBoolFlag() bool { return true }
+
+// Returns the value of t, using auto for TriAuto, e.g. opt.Color.Bool(isatty(os.Stdout))
+func (t TriState) Bool(auto bool) bool {
+  switch t {
+    case TriTrue:  return true
+    case TriFalse: return false
+    default:       return auto
+  }
+}
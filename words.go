@@ -0,0 +1,71 @@
+package cmdr
+import (
+  "errors"
+  "strings"
+)
+
+
+// Splits s into words the way a POSIX shell does, without expanding anything. Words are
+// separated by whitespace. Single quotes preserve everything up to the closing quote, double
+// quotes preserve everything but backslash escapes of ", \, $ and `, and a backslash outside
+// of quotes escapes the next character. A # at the start of a word begins a comment which
+// extends to the end of the line.
+func splitWords(s string) ([]string, error) {
+  var words []string
+  var word strings.Builder
+  inWord := false
+  for i := 0; i < len(s); i++ {
+    c := s[i]
+    switch {
+    case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+      if inWord {
+        words = append(words, word.String())
+        word.Reset()
+        inWord = false
+      }
+    case c == '#' && !inWord:
+      for i < len(s) && s[i] != '\n' {
+        i++
+      }
+    case c == '\\':
+      i++
+      if i == len(s) {
+        return nil, errors.New("unterminated backslash escape")
+      }
+      if s[i] != '\n' { // backslash-newline continues the line
+        word.WriteByte(s[i])
+        inWord = true
+      }
+    case c == '\'':
+      j := strings.IndexByte(s[i+1:], '\'')
+      if j == -1 {
+        return nil, errors.New("unterminated single quote")
+      }
+      word.WriteString(s[i+1:i+1+j])
+      i += 1 + j
+      inWord = true
+    case c == '"':
+      i++
+      for ; i < len(s) && s[i] != '"'; i++ {
+        if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) != -1 {
+          i++
+          if s[i] == '\n' {
+            continue
+          }
+        }
+        word.WriteByte(s[i])
+      }
+      if i == len(s) {
+        return nil, errors.New("unterminated double quote")
+      }
+      inWord = true
+    default:
+      word.WriteByte(c)
+      inWord = true
+    }
+  }
+  if inWord {
+    words = append(words, word.String())
+  }
+  return words, nil
+}
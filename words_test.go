@@ -0,0 +1,53 @@
+package cmdr
+import (
+  "reflect"
+  "strings"
+  "testing"
+)
+
+
+func TestSplitWords(t *testing.T) {
+  tests := []struct {
+    s     string
+    words []string
+    err   string
+  }{
+    {"", nil, ""},
+    {" \t\n", nil, ""},
+    {"a b  c", []string{"a", "b", "c"}, ""},
+    {"  a\tb\r\nc  ", []string{"a", "b", "c"}, ""},
+    {`'a b' "c d"`, []string{"a b", "c d"}, ""},
+    {`a'b c'd`, []string{"ab cd"}, ""},
+    {`'' ""`, []string{"", ""}, ""},
+    {`'a\b "c"'`, []string{`a\b "c"`}, ""},
+    {`"a\"b\\c\$d\e"`, []string{`a"b\c$d\e`}, ""},
+    {`"a'b"`, []string{"a'b"}, ""},
+    {`a\ b \'c`, []string{"a b", "'c"}, ""},
+    {"a\\\nb", []string{"ab"}, ""},
+    {"\"a\\\nb\"", []string{"ab"}, ""},
+    {"a # comment\nb", []string{"a", "b"}, ""},
+    {"# only a comment", nil, ""},
+    {"a#b", []string{"a#b"}, ""},
+    {`$HOME *.go`, []string{"$HOME", "*.go"}, ""},
+    {`a\`, nil, "unterminated backslash escape"},
+    {`'a`, nil, "unterminated single quote"},
+    {`"a`, nil, "unterminated double quote"},
+    {`"a\"`, nil, "unterminated double quote"},
+  }
+  for _, test := range tests {
+    words, err := splitWords(test.s)
+    if test.err != "" {
+      if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+        t.Errorf("splitWords(%q): error %v, want %q", test.s, err, test.err)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("splitWords(%q): unexpected error: %v", test.s, err)
+      continue
+    }
+    if (len(words) != 0 || len(test.words) != 0) && !reflect.DeepEqual(words, test.words) {
+      t.Errorf("splitWords(%q) = %q, want %q", test.s, words, test.words)
+    }
+  }
+}